@@ -0,0 +1,220 @@
+package function
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Expression is the node of the expression tree. Exactly one
+// of its fields must be set. The children are evaluated in order
+type Expression struct {
+	And  []Expression `json:"and,omitempty" yaml:"and,omitempty"`
+	Or   []Expression `json:"or,omitempty" yaml:"or,omitempty"`
+	Not  *Expression  `json:"not,omitempty" yaml:"not,omitempty"`
+	Grep *GrepFilter  `json:"grep,omitempty" yaml:"grep,omitempty"`
+	// ReferencedBy matches the documents which names are
+	// referenced by the other documents
+	ReferencedBy *Reference `json:"referencedBy,omitempty" yaml:"referencedBy,omitempty"`
+}
+
+// Reference selects the documents that are referenced by name
+type Reference struct {
+	// From selects the referencing documents among all documents
+	From *Expression `json:"from,omitempty" yaml:"from,omitempty"`
+	// Path is the field of the referencing documents with the name or
+	// with the map with name and namespace. The namespace of the referencing
+	// document is used if the reference doesn't have it
+	Path []string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+func (e *Expression) init() error {
+	count := 0
+	if e.And != nil {
+		count++
+	}
+	if e.Or != nil {
+		count++
+	}
+	if e.Not != nil {
+		count++
+	}
+	if e.Grep != nil {
+		count++
+	}
+	if e.ReferencedBy != nil {
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("exactly one of and, or, not, grep and referencedBy must be set")
+	}
+
+	for i := range e.And {
+		err := e.And[i].init()
+		if err != nil {
+			return fmt.Errorf("and[%d]: %w", i, err)
+		}
+	}
+	for i := range e.Or {
+		err := e.Or[i].init()
+		if err != nil {
+			return fmt.Errorf("or[%d]: %w", i, err)
+		}
+	}
+	if e.Not != nil {
+		err := e.Not.init()
+		if err != nil {
+			return fmt.Errorf("not: %w", err)
+		}
+	}
+	if e.Grep != nil {
+		err := e.Grep.init()
+		if err != nil {
+			return fmt.Errorf("grep: %w", err)
+		}
+	}
+	if r := e.ReferencedBy; r != nil {
+		if r.From == nil || len(r.Path) == 0 {
+			return fmt.Errorf("referencedBy: from and path must be set")
+		}
+		err := r.From.init()
+		if err != nil {
+			return fmt.Errorf("referencedBy: from: %w", err)
+		}
+	}
+	return nil
+}
+
+// match returns the items that match the expression. all
+// is the whole input that is used by ReferencedBy
+func (e *Expression) match(items, all []*yaml.RNode) (map[*yaml.RNode]bool, error) {
+	switch {
+	case e.And != nil:
+		for i := range e.And {
+			matched, err := e.And[i].match(items, all)
+			if err != nil {
+				return nil, fmt.Errorf("and[%d]: %w", i, err)
+			}
+			items = subset(items, matched)
+		}
+		return toSet(items), nil
+	case e.Or != nil:
+		r := map[*yaml.RNode]bool{}
+		for i := range e.Or {
+			matched, err := e.Or[i].match(items, all)
+			if err != nil {
+				return nil, fmt.Errorf("or[%d]: %w", i, err)
+			}
+			for p := range matched {
+				r[p] = true
+			}
+		}
+		return r, nil
+	case e.Not != nil:
+		matched, err := e.Not.match(items, all)
+		if err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+		r := map[*yaml.RNode]bool{}
+		for _, p := range items {
+			if !matched[p] {
+				r[p] = true
+			}
+		}
+		return r, nil
+	case e.Grep != nil:
+		out, err := e.Grep.Filter(items)
+		if err != nil {
+			return nil, err
+		}
+		return toSet(out), nil
+	case e.ReferencedBy != nil:
+		return e.ReferencedBy.match(items, all)
+	}
+	return map[*yaml.RNode]bool{}, nil
+}
+
+func (r *Reference) match(items, all []*yaml.RNode) (map[*yaml.RNode]bool, error) {
+	from, err := r.From.match(all, all)
+	if err != nil {
+		return nil, fmt.Errorf("referencedBy: from: %w", err)
+	}
+	refs := map[reference]bool{}
+	for _, p := range subset(all, from) {
+		meta, err := p.GetMeta()
+		if err != nil {
+			continue
+		}
+		val, err := p.Pipe(&yaml.PathMatcher{Path: r.Path})
+		if err != nil {
+			return nil, fmt.Errorf("referencedBy: %w", err)
+		}
+		if val == nil {
+			continue
+		}
+		for _, n := range val.Content() {
+			ref, ok := newReference(n, meta.Namespace)
+			if ok {
+				refs[ref] = true
+			}
+		}
+	}
+
+	out := map[*yaml.RNode]bool{}
+	for _, p := range items {
+		meta, err := p.GetMeta()
+		if err != nil {
+			continue
+		}
+		if refs[reference{namespace: meta.Namespace, name: meta.Name}] {
+			out[p] = true
+		}
+	}
+	return out, nil
+}
+
+// reference is the namespace and the name of the referenced document
+type reference struct {
+	namespace string
+	name      string
+}
+
+// newReference reads the reference from the name or from the map
+// with name and namespace fields. namespace of the referencing
+// document is used if the reference doesn't have it
+func newReference(n *yaml.Node, namespace string) (reference, bool) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return reference{namespace: namespace, name: n.Value}, true
+	case yaml.MappingNode:
+		rn := yaml.NewRNode(n)
+		name := rn.Field("name")
+		if name == nil || name.Value.YNode().Kind != yaml.ScalarNode {
+			return reference{}, false
+		}
+		if ns := rn.Field("namespace"); ns != nil && ns.Value.YNode().Value != "" {
+			namespace = ns.Value.YNode().Value
+		}
+		return reference{namespace: namespace, name: name.Value.YNode().Value}, true
+	}
+	return reference{}, false
+}
+
+func toSet(items []*yaml.RNode) map[*yaml.RNode]bool {
+	r := map[*yaml.RNode]bool{}
+	for _, p := range items {
+		r[p] = true
+	}
+	return r
+}
+
+// subset returns the items that are in set keeping the order
+func subset(items []*yaml.RNode, set map[*yaml.RNode]bool) []*yaml.RNode {
+	out := []*yaml.RNode{}
+	for _, p := range items {
+		if set[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
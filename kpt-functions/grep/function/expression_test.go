@@ -0,0 +1,302 @@
+package function
+
+import (
+	"bytes"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestExpression(t *testing.T) {
+	in := `apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc
+  namespace: metal3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node2-bmc
+  namespace: metal3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node3-bmc
+  namespace: other
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+  namespace: metal3
+spec:
+  bmc:
+    credentialsName: node1-bmc
+`
+	tc := []struct {
+		cfg         string
+		expectedOut string
+		expectedErr bool
+	}{
+		{
+			cfg: `
+expression:
+  and:
+  - grep:
+      path: [kind]
+      value: ^Secret$
+  - grep:
+      path: [metadata, namespace]
+      value: ^metal3$
+  - not:
+      referencedBy:
+        from:
+          grep:
+            path: [kind]
+            value: ^BareMetalHost$
+        path: [spec, bmc, credentialsName]
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: node2-bmc
+  namespace: metal3
+`,
+		},
+		{
+			cfg: `
+expression:
+  or:
+  - grep:
+      path: [metadata, name]
+      value: ^node3-bmc$
+  - and:
+    - grep:
+        path: [kind]
+        value: ^BareMetalHost$
+    - grep:
+        path: [spec, bmc]
+        operator: exists
+data:
+  flt: |
+    filters:
+    - path: [metadata, name]
+      value: ^node1-bmc$
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc
+  namespace: metal3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node3-bmc
+  namespace: other
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+  namespace: metal3
+spec:
+  bmc:
+    credentialsName: node1-bmc
+`,
+		},
+		{
+			cfg: `
+expression:
+  not:
+    grep:
+      path: [kind]
+      value: Secret
+  grep:
+    path: [kind]
+    value: Secret
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+expression:
+  and:
+  - referencedBy:
+      path: [spec]
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+expression:
+  or:
+  - grep:
+      path: [kind]
+      operator: unknown
+`,
+			expectedErr: true,
+		},
+	}
+
+	for i, ti := range tc {
+		cfg := Config{}
+		err := yaml.Unmarshal([]byte(ti.cfg), &cfg)
+		if err != nil {
+			t.Fatalf("can't unmarshal config %s: %v", ti.cfg, err)
+		}
+		f, err := NewFilter(&cfg)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("%d: expected error", i)
+			continue
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Fatalf("can't read input: %v", err)
+		}
+		nodes, err = f.Filter(nodes)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		out := &bytes.Buffer{}
+		err = kio.ByteWriter{Writer: out}.Write(nodes)
+		if err != nil {
+			t.Fatalf("can't write output: %v", err)
+		}
+		if out.String() != ti.expectedOut {
+			t.Errorf("%d: expected\n%s\ngot\n%s", i, ti.expectedOut, out.String())
+		}
+	}
+}
+
+func TestReferencedByNamespace(t *testing.T) {
+	in := `apiVersion: v1
+kind: Secret
+metadata:
+  name: bmc
+  namespace: metal3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: bmc
+  namespace: other
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: shared
+  namespace: metal3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: shared
+  namespace: other
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+  namespace: metal3
+spec:
+  bmc:
+    credentialsName: bmc
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: refs
+  namespace: other
+data:
+  ref:
+    name: shared
+    namespace: metal3
+`
+	tc := []struct {
+		cfg         string
+		expectedOut string
+	}{
+		{
+			cfg: `
+expression:
+  and:
+  - grep:
+      path: [kind]
+      value: ^Secret$
+  - referencedBy:
+      from:
+        grep:
+          path: [kind]
+          value: ^BareMetalHost$
+      path: [spec, bmc, credentialsName]
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: bmc
+  namespace: metal3
+`,
+		},
+		{
+			cfg: `
+expression:
+  and:
+  - grep:
+      path: [kind]
+      value: ^Secret$
+  - referencedBy:
+      from:
+        grep:
+          path: [kind]
+          value: ^ConfigMap$
+      path: [data, ref]
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: shared
+  namespace: metal3
+`,
+		},
+	}
+
+	for i, ti := range tc {
+		cfg := Config{}
+		err := yaml.Unmarshal([]byte(ti.cfg), &cfg)
+		if err != nil {
+			t.Fatalf("can't unmarshal config %s: %v", ti.cfg, err)
+		}
+		f, err := NewFilter(&cfg)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Fatalf("can't read input: %v", err)
+		}
+		nodes, err = f.Filter(nodes)
+		if err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+			continue
+		}
+		out := &bytes.Buffer{}
+		err = kio.ByteWriter{Writer: out}.Write(nodes)
+		if err != nil {
+			t.Fatalf("can't write output: %v", err)
+		}
+		if out.String() != ti.expectedOut {
+			t.Errorf("%d: expected\n%s\ngot\n%s", i, ti.expectedOut, out.String())
+		}
+	}
+}
@@ -2,7 +2,11 @@ package function
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/Masterminds/semver"
 	"k8s.io/apimachinery/pkg/api/resource"
 
 	"sigs.k8s.io/kustomize/kyaml/kio"
@@ -12,29 +16,52 @@ import (
 
 type Config struct {
 	Data map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	// Expression is the tree of and, or and not nodes with grepfilters.
+	// Documents that match it are added to the ones that match Data
+	Expression *Expression `json:"expression,omitempty" yaml:"expression,omitempty"`
+}
+
+const (
+	// GreaterThanOperator matches the values that are greater than
+	// Value. The values are compared as quantities, e.g. 16Gi > 8Gi
+	GreaterThanOperator = "greaterThan"
+	// LessThanOperator matches the values that are less than
+	// Value. The values are compared as quantities
+	LessThanOperator = "lessThan"
+	// SemverGreaterThanOperator matches the values that are greater
+	// than Value. The values are compared as semantic versions.
+	// If the value isn't a version, the part after the last : is
+	// used, so image tags can be compared
+	SemverGreaterThanOperator = "semverGreaterThan"
+	// ExistsOperator matches if the field exists
+	ExistsOperator = "exists"
+	// NotExistsOperator matches if the field doesn't exist
+	NotExistsOperator = "notExists"
+	// InOperator matches the values that are equal to one of Values
+	InOperator = "in"
+)
+
+// GrepFilter is filters.GrepFilter with the additional operators
+type GrepFilter struct {
+	filters.GrepFilter `yaml:",inline"`
+	// Operator is one of greaterThan, lessThan, semverGreaterThan,
+	// exists, notExists or in. If it isn't set filters.GrepFilter
+	// config is used as is
+	Operator string `yaml:"operator,omitempty"`
+	// Values is the list of values for in operator
+	Values []string `yaml:"values,omitempty"`
 }
 
 type AndFilter struct {
-	Filters []filters.GrepFilter `yaml:"filters,omitempty"`
+	Filters []GrepFilter `yaml:"filters,omitempty"`
 }
 
 type Filter struct {
-	Filters map[string]AndFilter
+	Filters    map[string]AndFilter
+	Expression *Expression
 }
 
 func NewFilter(cfg *Config) (kio.Filter, error) {
-	compare := func(a, b string) (int, error) {
-		qa, err := resource.ParseQuantity(a)
-		if err != nil {
-			return 0, fmt.Errorf("%s: %v", a, err)
-		}
-		qb, err := resource.ParseQuantity(b)
-		if err != nil {
-			return 0, err
-		}
-
-		return qa.Cmp(qb), err
-	}
 	f := Filter{Filters: map[string]AndFilter{}}
 	for name, fCfg := range cfg.Data {
 		af := AndFilter{}
@@ -42,15 +69,107 @@ func NewFilter(cfg *Config) (kio.Filter, error) {
 		if err != nil {
 			return nil, fmt.Errorf("can't unmarshal grep config: %v", err)
 		}
-		for _, gf := range af.Filters {
-			gf.Compare = compare
+		for i := range af.Filters {
+			err := af.Filters[i].init()
+			if err != nil {
+				return nil, fmt.Errorf("filter %s, element %d: %v", name, i, err)
+			}
 		}
 		f.Filters[name] = af
 	}
+	if cfg.Expression != nil {
+		err := cfg.Expression.init()
+		if err != nil {
+			return nil, fmt.Errorf("expression: %v", err)
+		}
+		f.Expression = cfg.Expression
+	}
 
 	return &f, nil
 }
 
+func compareQuantity(a, b string) (int, error) {
+	qa, err := resource.ParseQuantity(a)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", a, err)
+	}
+	qb, err := resource.ParseQuantity(b)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", b, err)
+	}
+	return qa.Cmp(qb), nil
+}
+
+// parseVersion parses the semantic version or the tag of the image,
+// e.g. registry:5000/nginx:1.19.2@sha256:...
+func parseVersion(s string) (*semver.Version, error) {
+	v, err := semver.NewVersion(s)
+	if err == nil {
+		return v, nil
+	}
+	ref := s
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	// the registry may have port, so the tag is after the last /
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i >= 0 {
+		return semver.NewVersion(ref[i+1:])
+	}
+	return nil, err
+}
+
+func compareSemver(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", a, err)
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", b, err)
+	}
+	return va.Compare(vb), nil
+}
+
+// init sets the filters.GrepFilter fields for the operator
+func (g *GrepFilter) init() error {
+	g.Compare = compareQuantity
+	switch g.Operator {
+	case "":
+	case GreaterThanOperator:
+		g.MatchType = filters.GreaterThan
+	case LessThanOperator:
+		g.MatchType = filters.LessThan
+	case SemverGreaterThanOperator:
+		g.MatchType = filters.GreaterThan
+		g.Compare = compareSemver
+		if _, err := parseVersion(g.Value); err != nil {
+			return fmt.Errorf("invalid version %s: %v", g.Value, err)
+		}
+	case ExistsOperator, NotExistsOperator:
+		g.MatchType = filters.Regexp
+		g.Value = ""
+		g.InvertMatch = g.Operator == NotExistsOperator
+	case InOperator:
+		quoted := make([]string, 0, len(g.Values))
+		for _, v := range g.Values {
+			quoted = append(quoted, regexp.QuoteMeta(v))
+		}
+		g.MatchType = filters.Regexp
+		g.Value = "^(" + strings.Join(quoted, "|") + ")$"
+	default:
+		return fmt.Errorf("unknown operator %s", g.Operator)
+	}
+	if g.Operator == GreaterThanOperator || g.Operator == LessThanOperator {
+		if _, err := resource.ParseQuantity(g.Value); err != nil {
+			return fmt.Errorf("invalid quantity %s: %v", g.Value, err)
+		}
+	}
+	return nil
+}
+
 func (f *AndFilter) Filter(items []*yaml.RNode) ([]*yaml.RNode, error) {
 	for i, af := range f.Filters {
 		x, err := af.Filter(items)
@@ -66,7 +185,14 @@ func (f *Filter) Filter(items []*yaml.RNode) ([]*yaml.RNode, error) {
 	r := map[*yaml.RNode]bool{}
 
 	// we're implementing 'or' case. 'and' is possible to make with pipeline.
-	for name, af := range f.Filters {
+	// the filters are executed in the order of names to get the same errors
+	names := make([]string, 0, len(f.Filters))
+	for name := range f.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		af := f.Filters[name]
 		out, err := af.Filter(items)
 		if err != nil {
 			return nil, fmt.Errorf("error executing filter %s: %v", name, err)
@@ -76,6 +202,16 @@ func (f *Filter) Filter(items []*yaml.RNode) ([]*yaml.RNode, error) {
 		}
 	}
 
+	if f.Expression != nil {
+		matched, err := f.Expression.match(items, items)
+		if err != nil {
+			return nil, fmt.Errorf("error executing expression: %v", err)
+		}
+		for p := range matched {
+			r[p] = true
+		}
+	}
+
 	out := []*yaml.RNode{}
 	for _, p := range items {
 		if _, ok := r[p]; ok {
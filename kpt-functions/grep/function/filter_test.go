@@ -79,6 +79,354 @@ data:
   x: y
 `,
 		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [status, capacity, memory]
+      value: 16Gi
+      operator: greaterThan
+`,
+			expectedOut: `apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+`,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [status, capacity, memory]
+      value: 16Gi
+      matchType: 8
+`,
+			expectedOut: `apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+`,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [spec, template, spec, containers, "[name=.*]", image]
+      value: 1.18.0
+      operator: semverGreaterThan
+`,
+			expectedOut: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [status, capacity]
+      operator: notExists
+`,
+			expectedOut: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [status, capacity]
+      operator: exists
+    - path: [metadata, labels, role]
+      operator: in
+      values: [master, control-plane]
+`,
+			expectedOut: `apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+`,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [kind]
+      operator: like
+`,
+			expectedErr: true,
+		},
+		{
+			in: `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+  labels:
+    role: worker
+status:
+  capacity:
+    memory: 8Gi
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: big
+  labels:
+    role: master
+status:
+  capacity:
+    memory: 64Gi
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: quay.io/nginx:1.19.2
+`,
+			cfg: `
+data:
+  flt: |
+    filters:
+    - path: [status, capacity, memory]
+      value: lots
+      operator: lessThan
+`,
+			expectedErr: true,
+		},
 	}
 
 	for i, ti := range tc {
@@ -119,3 +467,35 @@ data:
 	}
 
 }
+
+func TestParseVersion(t *testing.T) {
+	tc := []struct {
+		in          string
+		expected    string
+		expectedErr bool
+	}{
+		{in: "1.19.2", expected: "1.19.2"},
+		{in: "v1.19", expected: "1.19.0"},
+		{in: "quay.io/nginx:1.19.2", expected: "1.19.2"},
+		{in: "registry:5000/nginx:1.19.2", expected: "1.19.2"},
+		{in: "registry:5000/nginx:1.19.2@sha256:45b23dee08af5e43a7fea6c4cf9c25ccf269ee113168c19722f87876677c5cb2", expected: "1.19.2"},
+		{in: "registry:5000/nginx", expectedErr: true},
+		{in: "nginx@sha256:45b23dee08af5e43a7fea6c4cf9c25ccf269ee113168c19722f87876677c5cb2", expectedErr: true},
+	}
+	for _, ti := range tc {
+		v, err := parseVersion(ti.in)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("unexpected error for %s: %v", ti.in, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("expected error for %s, got %v", ti.in, v)
+			continue
+		}
+		if v.String() != ti.expected {
+			t.Errorf("expected %s for %s, got %s", ti.expected, ti.in, v.String())
+		}
+	}
+}
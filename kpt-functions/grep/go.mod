@@ -3,6 +3,7 @@ module github.com/aodinokov/noctl-airship-poc/kpt-functions/grep
 go 1.14
 
 require (
+	github.com/Masterminds/semver v1.5.0
 	k8s.io/apimachinery v0.19.2
 	sigs.k8s.io/kustomize/kyaml v0.4.1
 )
@@ -0,0 +1,204 @@
+package replacement
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Codecs that can be used in fieldrefs after |, e.g.
+// data.userData|base64|yaml|runcmd[0]
+const (
+	Base64Codec = "base64"
+	YamlCodec   = "yaml"
+	JSONCodec   = "json"
+	TomlCodec   = "toml"
+)
+
+// codec converts the string value of the field
+// to the node and back
+type codec struct {
+	// structured is set if decode returns a document
+	// rather than a string
+	structured bool
+	decode     func(string) (*yaml.RNode, error)
+	encode     func(*yaml.RNode) (string, error)
+}
+
+var codecs = map[string]codec{
+	Base64Codec: {decode: decodeBase64, encode: encodeBase64},
+	YamlCodec:   {structured: true, decode: decodeYaml, encode: encodeYaml},
+	JSONCodec:   {structured: true, decode: decodeJSON, encode: encodeJSON},
+	TomlCodec:   {structured: true, decode: decodeToml, encode: encodeToml},
+}
+
+func isCodec(fieldRef string) bool {
+	_, ok := codecs[fieldRef]
+	return ok
+}
+
+func decodeBase64(s string) (*yaml.RNode, error) {
+	// values may be wrapped to several lines
+	s = strings.Join(strings.Fields(s), "")
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.NewScalarRNode(string(b)), nil
+}
+
+func encodeBase64(node *yaml.RNode) (string, error) {
+	if node.YNode().Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("only scalar can be encoded to base64")
+	}
+	return base64.StdEncoding.EncodeToString([]byte(yaml.GetValue(node))), nil
+}
+
+func decodeYaml(s string) (*yaml.RNode, error) {
+	if strings.TrimSpace(s) == "" {
+		return yaml.NewMapRNode(nil), nil
+	}
+	node, err := yaml.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapDocument(node), nil
+}
+
+func encodeYaml(node *yaml.RNode) (string, error) {
+	return node.String()
+}
+
+func decodeJSON(s string) (*yaml.RNode, error) {
+	if strings.TrimSpace(s) == "" {
+		return yaml.NewMapRNode(nil), nil
+	}
+	if !json.Valid([]byte(s)) {
+		return nil, fmt.Errorf("invalid json")
+	}
+	node, err := yaml.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	node = unwrapDocument(node)
+	clearStyle(node.YNode())
+	return node, nil
+}
+
+func encodeJSON(node *yaml.RNode) (string, error) {
+	var out bytes.Buffer
+	err := writeJSON(&out, node.YNode())
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// writeJSON writes node as json keeping the order of the map keys
+func writeJSON(out *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			out.WriteString("null")
+			return nil
+		}
+		return writeJSON(out, n.Content[0])
+	case yaml.MappingNode:
+		out.WriteString("{")
+		for i := 0; i < len(n.Content); i += 2 {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			k, err := marshalJSON(n.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			out.Write(k)
+			out.WriteString(":")
+			err = writeJSON(out, n.Content[i+1])
+			if err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+	case yaml.SequenceNode:
+		out.WriteString("[")
+		for i := range n.Content {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			err := writeJSON(out, n.Content[i])
+			if err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case yaml.ScalarNode:
+		var v interface{}
+		err := n.Decode(&v)
+		if err != nil {
+			return err
+		}
+		b, err := marshalJSON(v)
+		if err != nil {
+			return err
+		}
+		out.Write(b)
+	default:
+		return fmt.Errorf("can't convert node of kind %d to json", n.Kind)
+	}
+	return nil
+}
+
+// marshalJSON is json.Marshal that doesn't escape html
+// symbols that are often used in scripts, e.g. &&
+func marshalJSON(v interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), nil
+}
+
+func decodeToml(s string) (*yaml.RNode, error) {
+	m := map[string]interface{}{}
+	_, err := toml.Decode(s, &m)
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return decodeYaml(string(b))
+}
+
+func encodeToml(node *yaml.RNode) (string, error) {
+	if node.YNode().Kind != yaml.MappingNode {
+		return "", fmt.Errorf("only map can be encoded to toml")
+	}
+	s, err := node.String()
+	if err != nil {
+		return "", err
+	}
+	m := map[string]interface{}{}
+	err = yaml.Unmarshal([]byte(s), &m)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = toml.NewEncoder(&out).Encode(m)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,89 @@
+package replacement
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestCodecs(t *testing.T) {
+	ts := []struct {
+		InCodec     string
+		InValue     string
+		ExpectedOut string
+		ExpectedErr bool
+	}{
+		{
+			InCodec:     Base64Codec,
+			InValue:     "aGVsbG8=",
+			ExpectedOut: "hello",
+		},
+		{
+			InCodec:     Base64Codec,
+			InValue:     "aGVs\n  bG8=\n",
+			ExpectedOut: "hello",
+		},
+		{
+			InCodec:     Base64Codec,
+			InValue:     "not base64!",
+			ExpectedErr: true,
+		},
+		{
+			InCodec:     YamlCodec,
+			InValue:     "a:\n  b: c\nd:\n- e\n",
+			ExpectedOut: "a:\n  b: c\nd:\n- e\n",
+		},
+		{
+			InCodec:     YamlCodec,
+			InValue:     "",
+			ExpectedOut: "{}\n",
+		},
+		{
+			InCodec:     JSONCodec,
+			InValue:     `{"runcmd": ["echo hi && reboot"], "hostname": "node01", "count": 2, "enabled": true}`,
+			ExpectedOut: `{"runcmd":["echo hi && reboot"],"hostname":"node01","count":2,"enabled":true}`,
+		},
+		{
+			InCodec:     JSONCodec,
+			InValue:     `{"runcmd": [}`,
+			ExpectedErr: true,
+		},
+		{
+			InCodec:     TomlCodec,
+			InValue:     "[plugins]\n  debug = true\n",
+			ExpectedOut: "[plugins]\n  debug = true\n",
+		},
+		{
+			InCodec:     TomlCodec,
+			InValue:     "[plugins\n",
+			ExpectedErr: true,
+		},
+	}
+
+	for _, ti := range ts {
+		c := codecs[ti.InCodec]
+		node, err := c.decode(ti.InValue)
+		if err != nil {
+			if !ti.ExpectedErr {
+				t.Errorf("unexpected error decoding %s with %s: %v", ti.InValue, ti.InCodec, err)
+			}
+			continue
+		}
+		if ti.ExpectedErr {
+			t.Errorf("expected error decoding %s with %s", ti.InValue, ti.InCodec)
+			continue
+		}
+		// structured values are checked by encoding them back
+		out := yaml.GetValue(node)
+		if c.structured {
+			out, err = c.encode(node)
+			if err != nil {
+				t.Errorf("unexpected error encoding %s with %s: %v", ti.InValue, ti.InCodec, err)
+				continue
+			}
+		}
+		if out != ti.ExpectedOut {
+			t.Errorf("decoding and encoding %s with %s expected %q, got %q", ti.InValue, ti.InCodec, ti.ExpectedOut, out)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package replacement
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// fieldWrite is the value set to the field by the replacement
+type fieldWrite struct {
+	replacement int
+	value       string
+	// shown is the value for messages, it's redacted if it's sensitive
+	shown string
+}
+
+// conflicts collects errors of the replacements and the values
+// they set to find the fields set to different values
+type conflicts struct {
+	errs   []string
+	writes map[string]fieldWrite
+}
+
+func newConflicts() *conflicts {
+	return &conflicts{writes: map[string]fieldWrite{}}
+}
+
+func (c *conflicts) add(replacement int, err error) {
+	c.errs = append(c.errs, fmt.Sprintf("replacement %d: %v", replacement, err))
+}
+
+// record remembers the values set by changes. Fields that are
+// merged aren't checked since several replacements can add
+// their parts to them.
+func (c *conflicts) record(changes []Change, t *Target) {
+	if t.MergeStrategy != "" && t.MergeStrategy != ReplaceMergeStrategy {
+		return
+	}
+	for _, ch := range changes {
+		key := fmt.Sprintf("%p/%s", ch.node.YNode(), normalizeFieldRef(ch.node, ch.FieldRef))
+		prev, ok := c.writes[key]
+		if ok && prev.value != ch.value {
+			c.errs = append(c.errs, fmt.Sprintf(
+				"replacement %d: conflicts with replacement %d: field %s of %s is set to %q and %q",
+				ch.Replacement, prev.replacement, ch.FieldRef, resourceID(ch.node), prev.shown, ch.NewValue))
+		}
+		c.writes[key] = fieldWrite{replacement: ch.Replacement, value: ch.value, shown: ch.NewValue}
+	}
+}
+
+// normalizeFieldRef replaces the sequence elements selected by index or
+// predicates in fieldRef with their positions in node, so the different
+// spellings of the same field, e.g. spec.containers.0.image and
+// spec.containers[name=c].image, give the same fieldref. The path
+// elements that can't be resolved are kept as is.
+func normalizeFieldRef(node *yaml.RNode, fieldRef string) string {
+	fr, pattern, err := splitFieldRefPattern(fieldRef)
+	if err != nil {
+		return fieldRef
+	}
+	fieldRefs, err := ParseFieldRefs(fr)
+	if err != nil {
+		return fieldRef
+	}
+	path, err := ParseFieldRef(fieldRefs[0])
+	if err != nil {
+		return fieldRef
+	}
+
+	cn := node
+	for i, p := range path {
+		if cn == nil {
+			break
+		}
+		if cn.YNode().Kind != yaml.SequenceNode {
+			cn, err = cn.Pipe(yaml.Lookup(p))
+			if err != nil {
+				cn = nil
+			}
+			continue
+		}
+		js, err := seqElementIndexes(cn, p)
+		if err != nil || len(js) != 1 || p == allElementsPath {
+			break
+		}
+		path[i] = fmt.Sprintf("[%d]", js[0])
+		cn = yaml.NewRNode(cn.Content()[js[0]])
+	}
+	fieldRefs[0] = joinFieldRef(path)
+	return joinFieldRefs(fieldRefs) + "%" + pattern
+}
+
+func (c *conflicts) err() error {
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d replacement errors:\n%s", len(c.errs), strings.Join(c.errs, "\n"))
+}
+
+// resourceID returns kind, namespace and name of node to use in messages
+func resourceID(node *yaml.RNode) string {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return "unknown resource"
+	}
+	if meta.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", meta.Kind, meta.Namespace, meta.Name)
+	}
+	return fmt.Sprintf("%s %s", meta.Kind, meta.Name)
+}
+
+// valueString returns value as string to use in messages
+func valueString(value interface{}) string {
+	node, ok := value.(*yaml.RNode)
+	if !ok {
+		return fmt.Sprintf("%v", value)
+	}
+	s, err := node.String()
+	if err != nil {
+		return fmt.Sprintf("%v", node)
+	}
+	return strings.TrimSpace(s)
+}
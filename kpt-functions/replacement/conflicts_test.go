@@ -0,0 +1,192 @@
+package replacement
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	in := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  a: old
+  image: nginx:1.0
+  hosts:
+  - name: h1
+    ip: 10.0.0.1
+`
+	tc := []struct {
+		cfg          string
+		expectedOut  string
+		expectedErrs []string
+	}{
+		{
+			cfg: `
+checkConflicts: true
+replacements:
+- source:
+    value: x
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+- source:
+    value: y
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+- source:
+    objref:
+      kind: Missing
+      name: missing
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.b
+- source:
+    value: z
+  target:
+    objref:
+      kind: Secret
+    fieldrefs:
+    - data.c
+`,
+			expectedErrs: []string{
+				"replacement 1: conflicts with replacement 0: field data.a of ConfigMap cfg is set to \"x\" and \"y\"",
+				"replacement 2: failed to find one resource",
+				"replacement 3: target objref",
+			},
+		},
+		{
+			cfg: `
+checkConflicts: true
+replacements:
+- source:
+    value: x
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+- source:
+    value: x
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+- source:
+    value: "2.0"
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.image%1.0%
+- source:
+    value: "docker.io/nginx"
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.image%nginx%
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  a: x
+  image: docker.io/nginx:2.0
+  hosts:
+  - name: h1
+    ip: 10.0.0.1
+`,
+		},
+		{
+			cfg: `
+checkConflicts: true
+replacements:
+- source:
+    value: 10.0.0.2
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.hosts.0.ip
+- source:
+    value: 10.0.0.3
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.hosts[name=h1].ip
+- source:
+    value: 10.0.0.3
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.hosts[-1].ip
+`,
+			expectedErrs: []string{
+				"replacement 1: conflicts with replacement 0: field data.hosts[name=h1].ip of ConfigMap cfg is set to \"10.0.0.2\" and \"10.0.0.3\"",
+			},
+		},
+	}
+
+	for i, ti := range tc {
+		fcfg := FunctionConfig{}
+		err := yaml.Unmarshal([]byte(ti.cfg), &fcfg)
+		if err != nil {
+			t.Errorf("can't unmarshal config %s: %v", ti.cfg, err)
+			continue
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Errorf("can't read input: %v", err)
+			continue
+		}
+
+		f := Function{Config: &fcfg}
+		err = f.Exec(nodes)
+
+		out := &bytes.Buffer{}
+		if werr := (kio.ByteWriter{Writer: out}).Write(nodes); werr != nil {
+			t.Errorf("write returned unexpected error %v", werr)
+			continue
+		}
+
+		if len(ti.expectedErrs) > 0 {
+			if err == nil {
+				t.Errorf("%d: expected error", i)
+				continue
+			}
+			for _, e := range ti.expectedErrs {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("%d: expected %q in error %v", i, e, err)
+				}
+			}
+			if out.String() != in {
+				t.Errorf("%d: items were changed despite errors:\n%s", i, out.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if out.String() != ti.expectedOut {
+			t.Errorf("%d: expected %s, got %s", i, ti.expectedOut, out.String())
+		}
+	}
+}
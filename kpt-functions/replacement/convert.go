@@ -0,0 +1,171 @@
+package replacement
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Types that can be set in Target.Type
+const (
+	StringType = "string"
+	IntType    = "int"
+	BoolType   = "bool"
+	FloatType  = "float"
+	YamlType   = "yaml"
+	JSONType   = "json"
+)
+
+const (
+	tagString = "!!str"
+	tagInt    = "!!int"
+	tagBool   = "!!bool"
+	tagFloat  = "!!float"
+)
+
+func isValidType(typ string) bool {
+	switch typ {
+	case "", StringType, IntType, BoolType, FloatType, YamlType, JSONType:
+		return true
+	}
+	return false
+}
+
+// inferType returns the type of the scalar node if it's
+// string, int, bool or float. Otherwise returns empty string.
+func inferType(node *yaml.RNode) string {
+	if node == nil || node.YNode().Kind != yaml.ScalarNode {
+		return ""
+	}
+	switch node.YNode().ShortTag() {
+	case tagString:
+		return StringType
+	case tagInt:
+		return IntType
+	case tagBool:
+		return BoolType
+	case tagFloat:
+		return FloatType
+	}
+	return ""
+}
+
+// isScalarValue returns true if value is string or scalar node
+func isScalarValue(value interface{}) bool {
+	node, ok := value.(*yaml.RNode)
+	return !ok || node.YNode().Kind == yaml.ScalarNode
+}
+
+// convertValueForTarget converts value to typ. If typ is empty
+// the type is inferred from the current value of the field
+// fieldRef in node. Non-scalar values replace strings as is.
+func convertValueForTarget(node *yaml.RNode, fieldRef string, value interface{}, typ string) (interface{}, error) {
+	if typ == "" {
+		fieldRefs, err := ParseFieldRefs(fieldRef)
+		if err != nil {
+			return nil, err
+		}
+		cur, err := getFieldValueImpl(node, fieldRefs)
+		if err != nil {
+			// the field may be created later - don't infer
+			return value, nil
+		}
+		typ = inferType(cur)
+		if typ == "" || (typ == StringType && !isScalarValue(value)) {
+			return value, nil
+		}
+		// the scalars from yaml, e.g. valueYaml, keep their own type
+		if node, ok := value.(*yaml.RNode); ok && node.YNode().Kind == yaml.ScalarNode {
+			return value, nil
+		}
+	}
+	return convertValue(value, typ)
+}
+
+// convertValue returns the node of type typ built from value
+func convertValue(value interface{}, typ string) (*yaml.RNode, error) {
+	if node, ok := value.(*yaml.RNode); ok {
+		switch typ {
+		case YamlType, JSONType:
+			return node, nil
+		case StringType:
+			if node.YNode().Kind == yaml.ScalarNode {
+				value = yaml.GetValue(node)
+				break
+			}
+			s, err := node.String()
+			if err != nil {
+				return nil, err
+			}
+			value = s
+		default:
+			if node.YNode().Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("can't convert non-scalar value to %s", typ)
+			}
+			value = yaml.GetValue(node)
+		}
+	}
+
+	svalue, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("value arg containes not expected type")
+	}
+
+	switch typ {
+	case StringType:
+		return newTaggedScalarRNode(svalue, tagString), nil
+	case IntType:
+		i, err := strconv.ParseInt(strings.TrimSpace(svalue), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert %q to %s: %w", svalue, typ, err)
+		}
+		return newTaggedScalarRNode(strconv.FormatInt(i, 10), tagInt), nil
+	case BoolType:
+		b, err := strconv.ParseBool(strings.TrimSpace(svalue))
+		if err != nil {
+			return nil, fmt.Errorf("can't convert %q to %s: %w", svalue, typ, err)
+		}
+		return newTaggedScalarRNode(strconv.FormatBool(b), tagBool), nil
+	case FloatType:
+		f, err := strconv.ParseFloat(strings.TrimSpace(svalue), 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert %q to %s: %w", svalue, typ, err)
+		}
+		return newTaggedScalarRNode(strconv.FormatFloat(f, 'g', -1, 64), tagFloat), nil
+	case YamlType:
+		node, err := yaml.Parse(svalue)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert %q to %s: %w", svalue, typ, err)
+		}
+		return unwrapDocument(node), nil
+	case JSONType:
+		if !json.Valid([]byte(svalue)) {
+			return nil, fmt.Errorf("can't convert %q to %s: invalid json", svalue, typ)
+		}
+		node, err := yaml.Parse(svalue)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert %q to %s: %w", svalue, typ, err)
+		}
+		node = unwrapDocument(node)
+		// output json in the same style as the rest of document
+		clearStyle(node.YNode())
+		return node, nil
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func newTaggedScalarRNode(value, tag string) *yaml.RNode {
+	node := yaml.NewScalarRNode(value)
+	node.YNode().Tag = tag
+	return node
+}
+
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
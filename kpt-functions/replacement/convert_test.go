@@ -0,0 +1,193 @@
+package replacement
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestConvertValue(t *testing.T) {
+	ts := []struct {
+		InValue      string
+		InType       string
+		ExpectedYaml string
+		ExpectedErr  bool
+	}{
+		{
+			InValue:      "3",
+			InType:       IntType,
+			ExpectedYaml: "3\n",
+		},
+		{
+			InValue:      " 0x10 ",
+			InType:       IntType,
+			ExpectedYaml: "16\n",
+		},
+		{
+			InValue:     "three",
+			InType:      IntType,
+			ExpectedErr: true,
+		},
+		{
+			InValue:      "True",
+			InType:       BoolType,
+			ExpectedYaml: "true\n",
+		},
+		{
+			InValue:     "yes please",
+			InType:      BoolType,
+			ExpectedErr: true,
+		},
+		{
+			InValue:      "0.50",
+			InType:       FloatType,
+			ExpectedYaml: "0.5\n",
+		},
+		{
+			InValue:      "6443",
+			InType:       StringType,
+			ExpectedYaml: "\"6443\"\n",
+		},
+		{
+			InValue:      "true",
+			InType:       StringType,
+			ExpectedYaml: "\"true\"\n",
+		},
+		{
+			InValue: "ntp:\n  servers:\n  - 0.pool.ntp.org\n",
+			InType:  YamlType,
+			ExpectedYaml: `ntp:
+  servers:
+  - 0.pool.ntp.org
+`,
+		},
+		{
+			InValue: `{"runcmd": ["reboot"], "hostname": "node01"}`,
+			InType:  JSONType,
+			ExpectedYaml: `runcmd:
+- reboot
+hostname: node01
+`,
+		},
+		{
+			InValue:     `{"runcmd": ["reboot"]`,
+			InType:      JSONType,
+			ExpectedErr: true,
+		},
+	}
+
+	for _, ti := range ts {
+		node, err := convertValue(ti.InValue, ti.InType)
+		if err != nil {
+			if !ti.ExpectedErr {
+				t.Errorf("unexpected error converting %s to %s: %v", ti.InValue, ti.InType, err)
+			}
+			continue
+		}
+		if ti.ExpectedErr {
+			t.Errorf("expected error converting %s to %s", ti.InValue, ti.InType)
+			continue
+		}
+		out, err := node.String()
+		if err != nil {
+			t.Errorf("can't convert node to string: %v", err)
+			continue
+		}
+		if out != ti.ExpectedYaml {
+			t.Errorf("converting %s to %s expected %q, got %q", ti.InValue, ti.InType, ti.ExpectedYaml, out)
+		}
+	}
+}
+
+func TestConvertValueForTarget(t *testing.T) {
+	ts := []struct {
+		InYaml       string
+		InField      string
+		InValue      string
+		InType       string
+		ExpectedYaml string
+		ExpectedErr  bool
+	}{
+		{
+			InYaml:       "spec:\n  replicas: 1\n",
+			InField:      "spec.replicas",
+			InValue:      "3",
+			ExpectedYaml: "3\n",
+		},
+		{
+			InYaml:       "spec:\n  online: false\n",
+			InField:      "spec.online",
+			InValue:      "true",
+			ExpectedYaml: "true\n",
+		},
+		{
+			InYaml:      "spec:\n  replicas: 1\n",
+			InField:     "spec.replicas",
+			InValue:     "many",
+			ExpectedErr: true,
+		},
+		{
+			InYaml:       "spec:\n  replicas: 1\n",
+			InField:      "spec.replicas",
+			InValue:      "3",
+			InType:       StringType,
+			ExpectedYaml: "\"3\"\n",
+		},
+		{
+			InYaml:       "spec:\n  image: busybox\n",
+			InField:      "spec.image",
+			InValue:      "nginx",
+			ExpectedYaml: "nginx\n",
+		},
+		{
+			InYaml:       "metadata:\n  labels:\n    ver: v1\n",
+			InField:      "metadata.labels.ver",
+			InValue:      "1.0",
+			ExpectedYaml: "\"1.0\"\n",
+		},
+		{
+			InYaml:       "spec:\n  containers:\n  - name: app\n    image: nginx\n",
+			InField:      "spec.containers[-1].name",
+			InValue:      "9",
+			ExpectedYaml: "\"9\"\n",
+		},
+		{
+			InYaml:       "spec: {}\n",
+			InField:      "spec.port",
+			InValue:      "6443",
+			ExpectedYaml: "6443\n",
+		},
+	}
+
+	for _, ti := range ts {
+		node, err := yaml.Parse(ti.InYaml)
+		if err != nil {
+			t.Errorf("wasn't able to parse inYaml %s: %v, trying to continue", ti.InYaml, err)
+			continue
+		}
+		v, err := convertValueForTarget(node, ti.InField, ti.InValue, ti.InType)
+		if err != nil {
+			if !ti.ExpectedErr {
+				t.Errorf("unexpected error converting %s for %s: %v", ti.InValue, ti.InField, err)
+			}
+			continue
+		}
+		if ti.ExpectedErr {
+			t.Errorf("expected error converting %s for %s", ti.InValue, ti.InField)
+			continue
+		}
+		out, ok := v.(string)
+		if !ok {
+			out, err = v.(*yaml.RNode).String()
+			if err != nil {
+				t.Errorf("can't convert node to string: %v", err)
+				continue
+			}
+		} else {
+			out += "\n"
+		}
+		if out != ti.ExpectedYaml {
+			t.Errorf("converting %s for %s expected %q, got %q", ti.InValue, ti.InField, ti.ExpectedYaml, out)
+		}
+	}
+}
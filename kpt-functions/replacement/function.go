@@ -2,24 +2,23 @@ package replacement
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"regexp"
 	"text/template"
 
-	"github.com/Masterminds/sprig"
+	"github.com/aodinokov/noctl-airship-poc/kpt-functions/templater/funcs"
 
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/kio/filters"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
-var (
-	// substring substitutions are appended to paths as: ...%VARNAME%
-	substringPatternRegex = regexp.MustCompile(`(\S+)%(\S+)%$`)
-)
-
 // Gvk identifies a Kubernetes API type.
 // https://github.com/kubernetes/community/blob/master/contributors/design-proposals/api-machinery/api-group.md
+// Group and Version are matched against apiVersion of resources.
+// Group `core` matches resources with apiVersion without group, e.g. `v1`.
+// Version may contain wildcards, e.g. `v1alpha*`.
 type Gvk struct {
 	Group   string `json:"group,omitempty" yaml:"group,omitempty"`
 	Version string `json:"version,omitempty" yaml:"version,omitempty"`
@@ -56,54 +55,154 @@ type SourceObjRef struct {
 	Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 }
 
+// MultiSourceRef is the source of the MultiRef template value.
+// Fieldref . refers to the whole object
+type MultiSourceRef struct {
+	// Name makes the value available in template as .Refs.<name>
+	Name     string        `json:"name,omitempty" yaml:"name,omitempty"`
+	ObjRef   *SourceObjRef `json:"objref" yaml:"objref"`
+	FieldRef string        `json:"fieldref" yaml:"fieldref"`
+}
+
 type MultiSourceObjRef struct {
-	Refs []struct {
-		ObjRef   *SourceObjRef `json:"objref" yaml:"objref"`
-		FieldRef string        `json:"fieldref" yaml:"fieldref"`
-	} `json:"refs,omitempty" yaml:"refs,omitempty"`
-	Template string `json:"template" yaml:"template"`
+	Refs     []MultiSourceRef `json:"refs,omitempty" yaml:"refs,omitempty"`
+	Template string           `json:"template" yaml:"template"`
+	// Type is the type of the template output: string (default)
+	// or yaml. yaml output is parsed to the structured value
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+// YAMLValue keeps the value of any yaml type as is
+type YAMLValue struct {
+	Node *yaml.Node
+}
+
+func (v *YAMLValue) UnmarshalYAML(n *yaml.Node) error {
+	v.Node = copyNode(n)
+	return nil
+}
+
+func (v YAMLValue) MarshalYAML() (interface{}, error) {
+	return v.Node, nil
 }
 
 // Source defines where a substitution is from
 // It can from two different kinds of sources
-//  - from a field of one resource
-//  - from a several sources and go template
-//  - from a string
+//   - from a field of one resource
+//   - from a several sources and go template
+//   - from a string
+//   - from a yaml value
 type Source struct {
 	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// ValueYAML is a literal value of any yaml type, e.g. list or map.
+	// Non-scalar values are set the same way as the values of object
+	// sources, e.g. they can be merged with mergeStrategy.
+	ValueYAML *YAMLValue `json:"valueYaml,omitempty" yaml:"valueYaml,omitempty"`
 
 	ObjRef   *SourceObjRef `json:"objref,omitempty" yaml:"objref,omitempty"`
 	FieldRef string        `json:"fieldref,omitempty" yaml:"fieldref,omitempty"`
 
 	MultiRef *MultiSourceObjRef `json:"multiref,omitempty" yaml:"multiref,omitempty"`
+
+	// Default is used if the source object or field doesn't exist
+	Default *YAMLValue `json:"default,omitempty" yaml:"default,omitempty"`
 }
 
 // ReplTarget defines where a substitution is to.
 type Target struct {
 	ObjRef    *Selector `json:"objref,omitempty" yaml:"objref,omitempty"`
 	FieldRefs []string  `json:"fieldrefs,omitempty" yaml:"fieldrefs,omitempty"`
+	// Type is the type the value is converted to before setting:
+	// string, int, bool, float, yaml or json. yaml and json mean
+	// that the value is a document that has to be parsed.
+	// If empty, int, bool and float types are inferred
+	// from the current value of the target field.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// MergeStrategy defines how non-scalar values are combined with the
+	// current value of the target field: replace (default), strategic-merge,
+	// append-unique or deep-merge.
+	MergeStrategy string `json:"mergeStrategy,omitempty" yaml:"mergeStrategy,omitempty"`
+	// MergeKey is the field that identifies the list elements
+	// for strategic-merge. Default is name.
+	MergeKey string `json:"mergeKey,omitempty" yaml:"mergeKey,omitempty"`
+	// Operation is set (default), delete, copy or move
+	Operation string `json:"operation,omitempty" yaml:"operation,omitempty"`
+	// ReplaceFirst replaces only the first match of the
+	// substring pattern of fieldrefs instead of all of them
+	ReplaceFirst bool `json:"replaceFirst,omitempty" yaml:"replaceFirst,omitempty"`
 }
 
 type Replacement struct {
 	Source *Source `json:"source" yaml:"source"`
 	Target *Target `json:"target" yaml:"target"`
+	// Optional replacement is skipped with warning if the source object
+	// or field doesn't exist and there is no default
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
 }
 
 type FunctionConfig struct {
 	Replacements []Replacement `json:"replacements,omitempty" yaml:"replacements,omitempty"`
+	// Provenance enables annotating the changed resources
+	// with the list of the changed fields and their sources
+	Provenance bool `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+	// Report is the name of the ReplacementReport resource with
+	// all changes that is added to the output if it's set
+	Report string `json:"report,omitempty" yaml:"report,omitempty"`
+	// Rules selects the documents of the input that contain
+	// the replacements in the same format as this config.
+	// Their replacements are applied after the ones of this config
+	// in the order of the documents and the documents are removed
+	// from the output by Filter
+	Rules *Selector `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// CheckConflicts enables two-phase execution: all replacements are
+	// applied to the copies of items and all errors and conflicts are
+	// reported at once. Items are changed only if there are no errors.
+	CheckConflicts bool `json:"checkConflicts,omitempty" yaml:"checkConflicts,omitempty"`
 }
 
 type Function struct {
 	Config *FunctionConfig
+	// Warnings contains the messages about skipped optional replacements
+	Warnings []string
+	// Changes contains the fields changed by the replacements
+	Changes []Change
 }
 
 func NewFunction(cfg *FunctionConfig) (*Function, error) {
-	for _, r := range cfg.Replacements {
+	err := validateReplacements(cfg.Replacements)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := Function{Config: cfg}
+	return &fn, nil
+}
+
+func validateReplacements(rs []Replacement) error {
+	for _, r := range rs {
+		if r.Target == nil {
+			return fmt.Errorf("`to` must be specified in one replacement")
+		}
+		// empty objref selects all resources, but it must be set explicitly
+		if r.Target.ObjRef == nil {
+			return fmt.Errorf("objref must be specified in the target")
+		}
+		if !isValidOperation(r.Target.Operation) {
+			return fmt.Errorf("unknown target operation %s", r.Target.Operation)
+		}
+		if r.Target.Operation == DeleteOperation {
+			if r.Source != nil {
+				return fmt.Errorf("source can't be used with delete operation")
+			}
+			continue
+		}
 		if r.Source == nil {
-			return nil, fmt.Errorf("`from` must be specified in one replacement")
+			return fmt.Errorf("`from` must be specified in one replacement")
 		}
-		if r.Target == nil {
-			return nil, fmt.Errorf("`to` must be specified in one replacement")
+		if r.Target.Operation == CopyOperation || r.Target.Operation == MoveOperation {
+			if r.Source.FieldRef == "" || r.Source.Value != "" || r.Source.ValueYAML != nil || r.Source.MultiRef != nil {
+				return fmt.Errorf("only objref and fieldref sources can be used with %s operation", r.Target.Operation)
+			}
 		}
 		count := 0
 		if r.Source.ObjRef != nil {
@@ -112,40 +211,186 @@ func NewFunction(cfg *FunctionConfig) (*Function, error) {
 		if r.Source.Value != "" {
 			count += 1
 		}
+		if r.Source.ValueYAML != nil {
+			count += 1
+		}
 		if r.Source.MultiRef != nil {
 			count += 1
+			if t := r.Source.MultiRef.Type; t != "" && t != StringType && t != YamlType {
+				return fmt.Errorf("unknown multiref type %s", t)
+			}
 		}
 		if count > 1 {
-			return nil, fmt.Errorf("only one of fieldref and value is allowed in one replacement")
+			return fmt.Errorf("only one of fieldref and value is allowed in one replacement")
+		}
+		if !isValidType(r.Target.Type) {
+			return fmt.Errorf("unknown target type %s", r.Target.Type)
+		}
+		if !isValidMergeStrategy(r.Target.MergeStrategy) {
+			return fmt.Errorf("unknown target merge strategy %s", r.Target.MergeStrategy)
+		}
+		for _, fieldRef := range r.Target.FieldRefs {
+			if _, _, err := compileFieldRefPattern(fieldRef); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
 
-	fn := Function{Config: cfg}
-	return &fn, nil
+// Filter loads the replacements from the documents selected by Rules,
+// applies all replacements to the rest of items and returns them
+// without the deleted resources
+func (f *Function) Filter(items []*yaml.RNode) ([]*yaml.RNode, error) {
+	items, rs, err := f.loadRules(items)
+	if err != nil {
+		return nil, err
+	}
+	return f.execReplacements(items, append(append([]Replacement{}, f.Config.Replacements...), rs...))
 }
 
+// Exec applies the replacements of the config to items.
+// Resources can be deleted only by Filter
 func (f *Function) Exec(items []*yaml.RNode) error {
-	for _, r := range f.Config.Replacements {
-
-		var value interface{}
+	for i, r := range f.Config.Replacements {
+		if r.Target.Operation == DeleteOperation && len(r.Target.FieldRefs) == 0 {
+			return fmt.Errorf("replacement %d deletes resources, use Filter instead of Exec", i)
+		}
+	}
+	_, err := f.execReplacements(items, f.Config.Replacements)
+	return err
+}
 
-		value, err := prepareValue(items, r.Source)
+func (f *Function) execReplacements(items []*yaml.RNode, rs []Replacement) ([]*yaml.RNode, error) {
+	f.Warnings = nil
+	f.Changes = nil
+	if !f.Config.CheckConflicts {
+		rest, err := f.exec(items, rs, nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		return rest, f.annotate()
+	}
+
+	copies := make([]*yaml.RNode, 0, len(items))
+	for _, item := range items {
+		copies = append(copies, yaml.NewRNode(copyNode(item.YNode())))
+	}
+	c := newConflicts()
+	rest, err := f.exec(copies, rs, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+	err = f.annotate()
+	if err != nil {
+		return nil, err
+	}
+	kept := map[*yaml.RNode]bool{}
+	for _, r := range rest {
+		kept[r] = true
+	}
+	out := make([]*yaml.RNode, 0, len(rest))
+	for i := range items {
+		if !kept[copies[i]] {
+			continue
+		}
+		*items[i].YNode() = *copies[i].YNode()
+		out = append(out, items[i])
+	}
+	return out, nil
+}
+
+// exec applies the replacements one by one and returns the items that
+// aren't deleted. If c is set the errors are collected to c and the next
+// replacements are applied anyway
+func (f *Function) exec(items []*yaml.RNode, rs []Replacement, c *conflicts) ([]*yaml.RNode, error) {
+	for i, r := range rs {
+		var matching []*yaml.RNode
+		var changes []Change
+		var err error
+		switch r.Target.Operation {
+		case DeleteOperation:
+			items, matching, changes, err = deleteTarget(items, r.Target)
+		case CopyOperation, MoveOperation:
+			matching, changes, err = f.copyTarget(items, i, r)
+		default:
+			var value interface{}
+			var skip bool
+			value, err = prepareValue(items, r.Source)
+			value, skip, err = f.resolveValue(i, r, value, err)
+			if skip {
+				continue
+			}
+			if err == nil {
+				matching, changes, err = apply(items, r.Target, value)
+			}
 		}
 
-		err = apply(items, r.Target, value)
+		for j := range changes {
+			changes[j].Replacement = i
+			changes[j].Source = sourceString(r.Source)
+		}
+		f.Changes = append(f.Changes, changes...)
+		if c == nil {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		if err != nil {
-			return err
+			c.add(i, err)
+			continue
 		}
+		if len(matching) == 0 {
+			c.add(i, fmt.Errorf("target objref %v doesn't match any resource", r.Target.ObjRef))
+			continue
+		}
+		c.record(changes, r.Target)
 	}
-	return nil
+	return items, nil
+}
+
+// resolveValue handles the source that doesn't exist: the default value
+// is used if it's set, optional replacement is skipped with warning
+func (f *Function) resolveValue(i int, r Replacement, value interface{}, err error) (interface{}, bool, error) {
+	if err == nil || !isNotFound(err) {
+		return value, false, err
+	}
+	switch {
+	case r.Source.Default != nil:
+		return prepareValueFromYAML(r.Source.Default.Node), false, nil
+	case r.Optional:
+		f.Warnings = append(f.Warnings,
+			fmt.Sprintf("optional replacement %d is skipped: %v", i, err))
+		return nil, true, nil
+	}
+	return value, false, err
+}
+
+// notFoundError is returned if the source object or field doesn't exist
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string {
+	return e.msg
+}
+
+func isNotFound(err error) bool {
+	var nf *notFoundError
+	return errors.As(err, &nf)
 }
 
 func prepareValue(items []*yaml.RNode, s *Source) (interface{}, error) {
 	if s.Value != "" {
 		return s.Value, nil
 	}
+	if s.ValueYAML != nil {
+		return prepareValueFromYAML(s.ValueYAML.Node), nil
+	}
 	if s.ObjRef != nil {
 		return prepareValueFromObjRefFieldRef(items, s.ObjRef, s.FieldRef)
 	}
@@ -155,6 +400,12 @@ func prepareValue(items []*yaml.RNode, s *Source) (interface{}, error) {
 	return "", nil
 }
 
+// prepareValueFromYAML returns the copy of n. Scalars keep
+// their tags, so e.g. quoted "5" is set as string
+func prepareValueFromYAML(n *yaml.Node) interface{} {
+	return yaml.NewRNode(copyNode(n))
+}
+
 func prepareValueFromObjRefFieldRef(
 	items []*yaml.RNode, objRef *SourceObjRef, fieldRef string) (interface{}, error) {
 
@@ -172,11 +423,13 @@ func prepareValueFromObjRefFieldRef(
 	return v, nil
 }
 
-func prepareValueFromMultiRef(items []*yaml.RNode, m *MultiSourceObjRef) (string, error) {
+func prepareValueFromMultiRef(items []*yaml.RNode, m *MultiSourceObjRef) (interface{}, error) {
 	data := struct {
-		Values []string
+		Values []interface{}
+		Refs   map[string]interface{}
 	}{
-		Values: make([]string, 0, len(m.Refs)),
+		Values: make([]interface{}, 0, len(m.Refs)),
+		Refs:   map[string]interface{}{},
 	}
 	for i := range m.Refs {
 		v, err := prepareValueFromObjRefFieldRef(
@@ -184,15 +437,23 @@ func prepareValueFromMultiRef(items []*yaml.RNode, m *MultiSourceObjRef) (string
 		if err != nil {
 			return "", fmt.Errorf("error preparing multiref %v ref %d: %w", m, i, err)
 		}
-		sv, ok := v.(string)
-		if !ok {
-			return "", fmt.Errorf("multiref sources can be scalars only")
+		// non-scalar values are available as maps and lists
+		if node, ok := v.(*yaml.RNode); ok {
+			var iv interface{}
+			err = node.YNode().Decode(&iv)
+			if err != nil {
+				return "", fmt.Errorf("error decoding multiref %v ref %d: %w", m, i, err)
+			}
+			v = iv
+		}
+		data.Values = append(data.Values, v)
+		if m.Refs[i].Name != "" {
+			data.Refs[m.Refs[i].Name] = v
 		}
-		data.Values = append(data.Values, sv)
 	}
 
 	var out bytes.Buffer
-	tmpl, err := template.New("tmpl").Funcs(sprig.TxtFuncMap()).Parse(m.Template)
+	tmpl, err := template.New("tmpl").Funcs(funcs.FuncMap()).Parse(m.Template)
 	if err != nil {
 		return "", fmt.Errorf("error parsing template %s: %w", m.Template, err)
 	}
@@ -203,38 +464,43 @@ func prepareValueFromMultiRef(items []*yaml.RNode, m *MultiSourceObjRef) (string
 			m.Template, data, err)
 	}
 
-	return out.String(), nil
+	if m.Type != YamlType {
+		return out.String(), nil
+	}
+	node, err := yaml.Parse(out.String())
+	if err != nil {
+		return "", fmt.Errorf("error parsing output of template %s: %w", m.Template, err)
+	}
+	return prepareValueFromYAML(node.YNode()), nil
 }
 
-func apply(items []*yaml.RNode, t *Target, value interface{}) error {
+// apply sets value to the target fields and returns
+// the matching nodes and the changes of their fields
+func apply(items []*yaml.RNode, t *Target, value interface{}) ([]*yaml.RNode, []Change, error) {
 	matching, err := t.ObjRef.Filter(items)
 	if err != nil {
-		return fmt.Errorf("error filtering by objref %v: %w", t.ObjRef, err)
+		return nil, nil, fmt.Errorf("error filtering by objref %v: %w", t.ObjRef, err)
 	}
+	changes := []Change{}
 	for _, node := range matching {
 		for _, fieldref := range t.FieldRefs {
-			err := setFieldValueHandlingRegex(node, fieldref, value)
+			oldValue := fieldValueString(node, fieldref)
+			err := setFieldValueHandlingRegex(node, fieldref, value, t)
 			if err != nil {
-				return fmt.Errorf("error setting value for objref %v, fieldref %s, value %s, node %v: %w",
-					t.ObjRef, fieldref, value, node, err)
+				return nil, changes, fmt.Errorf("error setting value for objref %v, fieldref %s, value %s, resource %s: %w",
+					t.ObjRef, fieldref, targetValueString(node, fieldref, value), resourceID(node), err)
 			}
+			changes = append(changes, newChange(node, fieldref, oldValue, fieldValueString(node, fieldref)))
 		}
 	}
-	return nil
+	return matching, changes, nil
 }
 
 func (g *Gvk) Filters() ([]kio.Filter, error) {
-	flts := []kio.Filter{}
-	if g.Group != "" {
-		flts = append(flts, filters.GrepFilter{Path: []string{"group"}, Value: g.Group})
-	}
-	if g.Version != "" {
-		flts = append(flts, filters.GrepFilter{Path: []string{"version"}, Value: g.Version})
+	if g.Group == "" && g.Version == "" && g.Kind == "" {
+		return []kio.Filter{}, nil
 	}
-	if g.Kind != "" {
-		flts = append(flts, filters.GrepFilter{Path: []string{"kind"}, Value: g.Kind})
-	}
-	return flts, nil
+	return []kio.Filter{GvkFilter{Group: g.Group, Version: g.Version, Kind: g.Kind}}, nil
 }
 
 func (s *Selector) Filters() ([]kio.Filter, error) {
@@ -306,7 +572,7 @@ func (s *SourceObjRef) FindOne(items []*yaml.RNode) (*yaml.RNode, error) {
 	}
 
 	if len(matching) == 0 {
-		return nil, fmt.Errorf("failed to find one resource matching from %v", s)
+		return nil, &notFoundError{msg: fmt.Sprintf("failed to find one resource matching from %v", s)}
 	}
 
 	return matching[0], nil
@@ -326,17 +592,41 @@ func findMatching(items []*yaml.RNode, flts []kio.Filter) ([]*yaml.RNode, error)
 	return p.Outputs[0].(*kio.PackageBuffer).Nodes, nil
 }
 
-func setFieldValueHandlingRegex(node *yaml.RNode, fieldRef string, value interface{}) error {
-	// fieldref can contain substtringpattern for regexp - we need to get it
-	substringPattern := ""
-	groups := substringPatternRegex.FindStringSubmatch(fieldRef)
-	if len(groups) == 3 {
-		fieldRef = groups[1]
-		substringPattern = groups[2]
+func setFieldValueHandlingRegex(node *yaml.RNode, fieldRef string, value interface{}, t *Target) error {
+	// fieldref can contain substring pattern for regexp - we need to get it
+	fieldRef, p, err := compileFieldRefPattern(fieldRef)
+	if err != nil {
+		return err
+	}
+	// the current value of each element selected by [*]
+	// is handled separately, e.g. for merge
+	fieldRefs, err := expandFieldRef(node, fieldRef)
+	if err != nil {
+		return err
+	}
+	for _, fr := range fieldRefs {
+		err := setFieldValueForTarget(node, fr, p, value, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func setFieldValueForTarget(node *yaml.RNode, fieldRef string, p *regexp.Regexp, value interface{}, t *Target) error {
+	var err error
+	if p != nil {
 		svalue, ok := value.(string)
+		if vnode, isNode := value.(*yaml.RNode); isNode && vnode.YNode().Kind == yaml.ScalarNode {
+			svalue, ok = vnode.YNode().Value, true
+		}
 		if !ok {
-			return fmt.Errorf("regex fieldref can be used only with scalar sources")
+			// non-scalar sources are embedded as json
+			b, err := value.(*yaml.RNode).MarshalJSON()
+			if err != nil {
+				return fmt.Errorf("wasn't able to render value for fieldref %s: %w", fieldRef, err)
+			}
+			svalue = string(b)
 		}
 
 		// calculate real value
@@ -351,12 +641,20 @@ func setFieldValueHandlingRegex(node *yaml.RNode, fieldRef string, value interfa
 			return fmt.Errorf("regex fieldref can be used only for scalar fields target: %s", fieldRef)
 		}
 
-		p := regexp.MustCompile(substringPattern)
-		if !p.MatchString(sv) {
-			return fmt.Errorf("wasn't able to match pattern %s with value %s",
-				substringPattern, sv)
+		value, err = replacePattern(p, sv, svalue, t.ReplaceFirst)
+		if err != nil {
+			return err
 		}
-		value = p.ReplaceAllString(sv, svalue)
+	}
+
+	value, err = convertValueForTarget(node, fieldRef, value, t.Type)
+	if err != nil {
+		return fmt.Errorf("wasn't able to convert value for fieldref %s: %w", fieldRef, err)
+	}
+
+	value, err = mergeValueForTarget(node, fieldRef, value, t.MergeStrategy, t.MergeKey)
+	if err != nil {
+		return fmt.Errorf("wasn't able to merge value for fieldref %s: %w", fieldRef, err)
 	}
 
 	return setFieldValue(node, fieldRef, value)
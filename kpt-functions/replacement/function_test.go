@@ -241,13 +241,13 @@ spec:
         command: ["printenv"]
         args:
         - example.com
-        - 8080
+        - "8080"
       - name: busybox
         image: busybox:latest
         args:
         - echo
         - example.com
-        - 8080
+        - "8080"
 ---
 apiVersion: v1
 kind: ConfigMap
@@ -920,6 +920,862 @@ spec:
       containers:
       - image: busybox:12345
         name: myapp-container
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      group: infrastructure.cluster.x-k8s.io
+      version: v1alpha3
+      kind: Metal3Cluster
+      name: target-cluster
+    fieldref: spec.controlPlaneEndpoint.host
+  target:
+    objref:
+      group: metal3.io
+      version: v1alpha*
+    fieldrefs:
+    - spec.endpoint
+- source:
+    objref:
+      group: core
+      kind: Secret
+      name: target-cluster-ca
+    fieldref: type
+  target:
+    objref:
+      group: cluster.x-k8s.io
+      kind: Cluster
+    fieldrefs:
+    - spec.caType`,
+			in: `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: target-cluster-ca
+type: kubernetes.io/tls
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Cluster
+metadata:
+  name: target-cluster
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha3
+kind: Metal3Cluster
+metadata:
+  name: target-cluster
+spec:
+  controlPlaneEndpoint:
+    host: 10.23.25.102
+    port: 6443
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node01
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: target-cluster-ca
+type: kubernetes.io/tls
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Cluster
+metadata:
+  name: target-cluster
+spec:
+  caType: kubernetes.io/tls
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha3
+kind: Metal3Cluster
+metadata:
+  name: target-cluster
+spec:
+  controlPlaneEndpoint:
+    host: 10.23.25.102
+    port: 6443
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node01
+spec:
+  endpoint: 10.23.25.102
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.PORT
+  target:
+    objref:
+      kind: Service
+    fieldrefs:
+    - spec.ports[name=http].port
+    - spec.ports[name=http].targetPort
+    type: int
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.ONLINE
+  target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.online
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.ONLINE
+  target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.description
+    type: string`,
+			in: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  PORT: "8080"
+  ONLINE: "true"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  ports:
+  - name: http
+    port: 80
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node01
+spec:
+  online: false
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  PORT: "8080"
+  ONLINE: "true"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  ports:
+  - name: http
+    port: 8080
+    targetPort: 8080
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node01
+spec:
+  online: true
+  description: "true"
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    value: eight
+  target:
+    objref:
+      kind: Service
+    fieldrefs:
+    - spec.ports[name=http].port`,
+			in: `
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+spec:
+  ports:
+  - name: http
+    port: 80
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      name: networking
+    fieldref: spec.networks
+  target:
+    objref:
+      kind: Metal3DataTemplate
+    fieldrefs:
+    - spec.networkData.networks.ipv4
+    mergeStrategy: strategic-merge
+    mergeKey: id`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: networking
+spec:
+  networks:
+  - id: oam-ipv4
+    link: bond0
+  - id: storage-ipv4
+    link: storage
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha4
+kind: Metal3DataTemplate
+metadata:
+  name: node01
+spec:
+  networkData:
+    networks:
+      ipv4:
+      - id: oam-ipv4
+        link: oam
+        ipAddressFromIPPool: oam-pool
+      - id: site-ipv4
+        link: site
+`,
+			expectedOut: `apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: networking
+spec:
+  networks:
+  - id: oam-ipv4
+    link: bond0
+  - id: storage-ipv4
+    link: storage
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha4
+kind: Metal3DataTemplate
+metadata:
+  name: node01
+spec:
+  networkData:
+    networks:
+      ipv4:
+      - id: oam-ipv4
+        link: bond0
+        ipAddressFromIPPool: oam-pool
+      - id: site-ipv4
+        link: site
+      - id: storage-ipv4
+        link: storage
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      kind: Secret
+      name: node1-bmc-secret
+    fieldref: data.password|base64
+  target:
+    objref:
+      kind: Secret
+      name: node1-user-data
+    fieldrefs:
+    - data.userData|base64|yaml|chpasswd.list`,
+			in: `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc-secret
+data:
+  password: cm9vdDpkZXBsb1khSzhz
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-user-data
+data:
+  userData: cnVuY21kOgotIGVjaG8gaGkgJiYgcmVib290Cg==
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc-secret
+data:
+  password: cm9vdDpkZXBsb1khSzhz
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-user-data
+data:
+  userData: cnVuY21kOgotIGVjaG8gaGkgJiYgcmVib290CmNocGFzc3dkOgogIGxpc3Q6IHJvb3Q6ZGVwbG9ZIUs4cwo=
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    multiref:
+      refs:
+      - objref:
+          kind: VariableCatalogue
+          name: source
+        fieldref: values.cidr
+      - objref:
+          kind: VariableCatalogue
+          name: source
+        fieldref: values.mac
+      template: |-
+        {{ cidrHost (index .Values 0) 10 }} {{ macAdd (index .Values 1) 1 }}
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.host`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  cidr: 10.23.25.0/24
+  mac: 00:aa:bb:cc:dd:ee
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  host: x
+`,
+			expectedOut: `apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  cidr: 10.23.25.0/24
+  mac: 00:aa:bb:cc:dd:ee
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  host: 10.23.25.10 00:aa:bb:cc:dd:ef
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    valueYaml:
+    - 1.pool.ntp.org
+    - 2.pool.ntp.org
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.ntp
+    mergeStrategy: append-unique
+- source:
+    valueYaml:
+      site: east
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - metadata.labels
+    - spec.labels
+    mergeStrategy: deep-merge
+- source:
+    valueYaml: 3
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - spec.replicas
+- source:
+    valueYaml: "5"
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - metadata.annotations.retries
+- source:
+    valueYaml: 2
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.image%TAG%`,
+			in: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  labels:
+    app: ntp
+data:
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+  image: ntp:TAG
+spec:
+  replicas: 1
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  labels:
+    app: ntp
+    site: east
+  annotations:
+    retries: "5"
+data:
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+  - 2.pool.ntp.org
+  image: ntp:2
+spec:
+  replicas: 3
+  labels:
+    site: east
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.ntp
+    default:
+    - 0.pool.ntp.org
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.ntp
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: missing
+    fieldref: values.proxy
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.proxy
+  optional: true
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.dns
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.dns
+  optional: true`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  dns: 8.8.8.8
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  proxy: none
+`,
+			expectedOut: `apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  dns: 8.8.8.8
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  proxy: none
+  ntp:
+  - 0.pool.ntp.org
+  dns: 8.8.8.8
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.ntp.5
+    default: 0.pool.ntp.org
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.ntp
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.ntp[-3]
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.backup
+  optional: true
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.monitoring
+    default: "true"
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - metadata.labels.monitoring`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  ntp:
+  - 1.pool.ntp.org
+  - 2.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+`,
+			expectedOut: `apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  ntp:
+  - 1.pool.ntp.org
+  - 2.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  labels:
+    monitoring: "true"
+data:
+  ntp: 0.pool.ntp.org
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: notImportantHere
+replacements:
+- source:
+    objref:
+      kind: VariableCatalogue
+      name: source
+    fieldref: values.ntp
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.ntp`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: source
+values:
+  dns: 8.8.8.8
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    valueYaml:
+      servers: [1.pool.ntp.org]
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.config%CONFIG%
+- source:
+    value: --log=debug --previous-log=${level}
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.flags%--log=(?P<level>\w+)%
+    replaceFirst: true
+`,
+			in: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  config: 'ntp: CONFIG'
+  flags: --log=info --log=warn
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  config: 'ntp: {"servers":["1.pool.ntp.org"]}'
+  flags: --log=debug --previous-log=info --log=warn
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    multiref:
+      refs:
+      - name: networking
+        objref:
+          kind: VariableCatalogue
+          name: networking
+        fieldref: values
+      - name: catalogue
+        objref:
+          kind: VariableCatalogue
+          name: networking
+        fieldref: .
+      template: |
+        url: http://{{ .Refs.networking.ironicIP }}:{{ index .Values 0 "port" }}
+        source: {{ .Refs.catalogue.metadata.name }}
+        ntp:
+        {{- range .Refs.networking.ntp }}
+        - {{ . }}
+        {{- end }}
+      type: yaml
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data
+    mergeStrategy: deep-merge
+`,
+			in: `
+apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: networking
+values:
+  ironicIP: 10.23.24.101
+  port: 6385
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  keep: value
+`,
+			expectedOut: `apiVersion: airshipit.org/v1alpha1
+kind: VariableCatalogue
+metadata:
+  name: networking
+values:
+  ironicIP: 10.23.24.101
+  port: 6385
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  keep: value
+  url: http://10.23.24.101:6385
+  source: networking
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    value: 10.23.24.101
+  target:
+    objref:
+      kind: Deployment
+    fieldrefs:
+    - spec.template.spec.containers[name=ironic].env[name=PROVISIONING_IP].value
+    - spec.template.spec.containers[name=dnsmasq].env[name=PROVISIONING_IP].value
+`,
+			in: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ironic
+spec:
+  template:
+    spec:
+      containers:
+      - name: ironic
+        env:
+        - name: PROVISIONING_INTERFACE
+          value: pxe0
+`,
+			expectedOut: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ironic
+spec:
+  template:
+    spec:
+      containers:
+      - name: ironic
+        env:
+        - name: PROVISIONING_INTERFACE
+          value: pxe0
+        - name: PROVISIONING_IP
+          value: 10.23.24.101
+      - name: dnsmasq
+        env:
+        - name: PROVISIONING_IP
+          value: 10.23.24.101
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    valueYaml:
+    - name: A
+      value: a
+  target:
+    objref:
+      kind: Deployment
+    fieldrefs:
+    - spec.template.spec.containers[*].env
+    mergeStrategy: append-unique
+`,
+			in: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: b
+        env:
+        - name: B
+          value: b
+      - name: c
+        env:
+        - name: C
+          value: c
+        - name: A
+          value: a
+`,
+			expectedOut: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: b
+        env:
+        - name: B
+          value: b
+        - name: A
+          value: a
+      - name: c
+        env:
+        - name: C
+          value: c
+        - name: A
+          value: a
 `,
 		},
 	}
@@ -959,3 +1815,25 @@ spec:
 		}
 	}
 }
+
+func TestOptionalWarnings(t *testing.T) {
+	f := Function{Config: &FunctionConfig{
+		Replacements: []Replacement{
+			{
+				Source: &Source{
+					ObjRef:   &SourceObjRef{Name: "missing"},
+					FieldRef: "values.a",
+				},
+				Target:   &Target{ObjRef: &Selector{}, FieldRefs: []string{"data.a"}},
+				Optional: true,
+			},
+		},
+	}}
+	err := f.Exec(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.Warnings) != 1 {
+		t.Errorf("expected 1 warning, got %v", f.Warnings)
+	}
+}
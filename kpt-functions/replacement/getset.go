@@ -6,15 +6,31 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+const (
+	// allElementsPath selects all elements of the sequence
+	allElementsPath = "[*]"
+	// appendElementPath adds a new element to the end of the sequence
+	appendElementPath = "[-]"
+)
+
+func isQuote(r rune) bool {
+	return r == '"' || r == '\''
+}
+
+// ParseFieldRefs splits the fieldref to the list of fieldrefs of embedded
+// documents separated by |.
 func ParseFieldRefs(in string) ([]string, error) {
 	var cur bytes.Buffer
 	out := []string{}
 	var state int
+	var quote rune
+	segStart := true
 	for i := 0; i < len(in); {
 		r, size := utf8.DecodeRuneInString(in[i:])
 
@@ -25,32 +41,53 @@ func ParseFieldRefs(in string) ([]string, error) {
 					out = append(out, cur.String())
 					cur = bytes.Buffer{}
 				}
+				segStart = true
 			} else if r == '[' {
 				cur.WriteRune(r)
 				state = 1
+			} else if isQuote(r) && segStart {
+				cur.WriteRune(r)
+				quote = r
+				state = 2
 			} else {
 				cur.WriteRune(r)
+				segStart = r == '.'
 			}
 		case 1: // state inside []
 			cur.WriteRune(r)
 			if r == ']' {
 				state = 0
+				segStart = false
+			}
+		case 2: // state inside quotes
+			cur.WriteRune(r)
+			if r == quote {
+				state = 0
+				segStart = false
 			}
 		}
 		i += size
 	}
 
-	if state != 0 {
+	if state == 1 {
 		return nil, fmt.Errorf("unclosed [")
 	}
+	if state == 2 {
+		return nil, fmt.Errorf("unclosed %c", quote)
+	}
 
 	return append(out, cur.String()), nil
 }
 
+// ParseFieldRef splits the fieldref to the path elements.
+// Elements are separated by . or enclosed in [].
+// Keys that contain . can be quoted, e.g.
+// metadata.annotations."config.kubernetes.io/function"
 func ParseFieldRef(in string) ([]string, error) {
 	var cur bytes.Buffer
 	out := []string{}
 	var state int
+	var quote rune
 	for i := 0; i < len(in); {
 		r, size := utf8.DecodeRuneInString(in[i:])
 
@@ -68,6 +105,9 @@ func ParseFieldRef(in string) ([]string, error) {
 				}
 				cur.WriteRune(r)
 				state = 1
+			} else if isQuote(r) && cur.String() == "" {
+				quote = r
+				state = 2
 			} else {
 				cur.WriteRune(r)
 			}
@@ -76,17 +116,28 @@ func ParseFieldRef(in string) ([]string, error) {
 			if r == ']' {
 				state = 0
 			}
+		case 2: // state inside quotes
+			if r == quote {
+				state = 0
+			} else {
+				cur.WriteRune(r)
+			}
 		}
 		i += size
 	}
 
-	if state != 0 {
+	if state == 1 {
 		return nil, fmt.Errorf("unclosed [")
 	}
+	if state == 2 {
+		return nil, fmt.Errorf("unclosed %c", quote)
+	}
 
 	return append(out, cur.String()), nil
 }
 
+// seqNodeIndexPath returns index from path element in form N or [N].
+// Negative index counts from the end of sequence.
 func seqNodeIndexPath(p string) (int64, error) {
 	if p[0] == '[' && p[len(p)-1] == ']' {
 		p = p[1 : len(p)-1]
@@ -95,10 +146,43 @@ func seqNodeIndexPath(p string) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	return i, nil
+}
+
+// seqNodeIndex converts possibly negative index i
+// to the position in sequence with l elements.
+func seqNodeIndex(i int64, l int) (int, error) {
 	if i < 0 {
-		return 0, fmt.Errorf("index can't be negative. got %d", i)
+		i += int64(l)
+		if i < 0 {
+			return 0, fmt.Errorf("index %d is too small", i-int64(l))
+		}
 	}
-	return i, nil
+	if i >= int64(l) {
+		return 0, fmt.Errorf("index %d is too big", i)
+	}
+	return int(i), nil
+}
+
+// unwrapDocument returns the content of the document node
+// that is returned by yaml.Parse
+func unwrapDocument(rn *yaml.RNode) *yaml.RNode {
+	if rn.YNode().Kind == yaml.DocumentNode && len(rn.Content()) == 1 {
+		return yaml.NewRNode(rn.Content()[0])
+	}
+	return rn
+}
+
+func copyNode(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.Content = make([]*yaml.Node, 0, len(n.Content))
+	for _, cn := range n.Content {
+		c.Content = append(c.Content, copyNode(cn))
+	}
+	return &c
 }
 
 func getFieldValue(node *yaml.RNode, fieldRef string) (interface{}, error) {
@@ -112,7 +196,7 @@ func getFieldValue(node *yaml.RNode, fieldRef string) (interface{}, error) {
 		return nil, err
 	}
 	if node == nil {
-		return nil, fmt.Errorf("field %s doesn't exist", fieldRef)
+		return nil, &notFoundError{msg: fmt.Sprintf("field %s doesn't exist", fieldRef)}
 	}
 	if node.YNode().Kind == yaml.ScalarNode {
 		return yaml.GetValue(node), nil
@@ -127,46 +211,125 @@ func getFieldValueImpl(node *yaml.RNode, fieldRefs []string) (*yaml.RNode, error
 		return nil, err
 	}
 
-	cn := node
-	for _, p := range path {
-
-		// index case
-		if cn.YNode().Kind == yaml.SequenceNode {
-			i, err := seqNodeIndexPath(p)
-
-			//log.Printf("get: found index %d", i)
+	nodes, fanout, err := lookupFieldNodes(node, path)
+	if err != nil {
+		return nil, err
+	}
 
-			if err == nil {
-				content := cn.Content()
-				if i >= int64(len(content)) {
-					return nil, fmt.Errorf("index %d is too big", i)
-				}
-				cn = yaml.NewRNode(content[i])
-				continue
+	if len(fieldRefs) > 1 {
+		included := []*yaml.RNode{}
+		for _, cn := range nodes {
+			in, err := getIncludedFieldValue(cn, fieldRefs[1:])
+			if err != nil {
+				return nil, err
+			}
+			if in != nil {
+				included = append(included, in)
 			}
 		}
+		nodes = included
+	}
 
-		// default case - use lookup
-		cn, err = cn.Pipe(yaml.Lookup(p))
-		if err != nil {
-			return nil, err
+	if fanout {
+		seq := yaml.NewListRNode()
+		for _, cn := range nodes {
+			seq.YNode().Content = append(seq.YNode().Content, cn.YNode())
 		}
+		return seq, nil
+	}
+	if len(nodes) == 0 {
+		return nil, nil
 	}
+	return nodes[0], nil
+}
 
-	if len(fieldRefs) == 1 {
-		return cn, nil
+// getIncludedFieldValue gets the value from the string of the scalar
+// node cn. The string is decoded by codecs in fieldRefs. If there is no
+// codec specified the string is decoded as yaml.
+func getIncludedFieldValue(cn *yaml.RNode, fieldRefs []string) (*yaml.RNode, error) {
+	c, ok := codecs[fieldRefs[0]]
+	if ok {
+		fieldRefs = fieldRefs[1:]
+	} else {
+		c = codecs[YamlCodec]
 	}
 
 	if cn.YNode().Kind != yaml.ScalarNode {
-		return nil, fmt.Errorf("node %v isn't scalar", path)
+		return nil, fmt.Errorf("node %v isn't scalar", cn.FieldPath())
 	}
 
-	node, err = yaml.Parse(yaml.GetValue(cn))
+	in, err := c.decode(yaml.GetValue(cn))
 	if err != nil {
 		return nil, err
 	}
 
-	return getFieldValueImpl(node, fieldRefs[1:])
+	if len(fieldRefs) == 0 {
+		return in, nil
+	}
+	if !c.structured || isCodec(fieldRefs[0]) {
+		return getIncludedFieldValue(in, fieldRefs)
+	}
+	return getFieldValueImpl(in, fieldRefs)
+}
+
+// lookupFieldNodes returns all nodes that match path.
+// fanout is set if path contains [*] and the result
+// must be considered as a list.
+func lookupFieldNodes(node *yaml.RNode, path []string) ([]*yaml.RNode, bool, error) {
+	nodes := []*yaml.RNode{node}
+	fanout := false
+	for _, p := range path {
+		next := []*yaml.RNode{}
+		for _, cn := range nodes {
+			if cn.YNode().Kind == yaml.SequenceNode {
+				content := cn.Content()
+				if p == allElementsPath {
+					fanout = true
+					for i := range content {
+						next = append(next, yaml.NewRNode(content[i]))
+					}
+					continue
+				}
+				if p == appendElementPath {
+					return nil, false, fmt.Errorf("%s can be used only in target fieldrefs", p)
+				}
+
+				// index case
+				i, err := seqNodeIndexPath(p)
+				if err == nil {
+					j, err := seqNodeIndex(i, len(content))
+					if err != nil {
+						// the same as missing field, so optional and default cover it
+						return nil, false, &notFoundError{msg: err.Error()}
+					}
+					next = append(next, yaml.NewRNode(content[j]))
+					continue
+				}
+
+				preds, ok, err := parsePredicates(p)
+				if err != nil {
+					return nil, false, err
+				}
+				if ok {
+					if j := findPredicates(cn, preds); j >= 0 {
+						next = append(next, yaml.NewRNode(content[j]))
+					}
+					continue
+				}
+			}
+
+			// default case - use lookup
+			cnl, err := cn.Pipe(yaml.Lookup(p))
+			if err != nil {
+				return nil, false, err
+			}
+			if cnl != nil {
+				next = append(next, cnl)
+			}
+		}
+		nodes = next
+	}
+	return nodes, fanout, nil
 }
 
 func guessNodeKind(i int, path []string, node *yaml.RNode) (yaml.Kind, error) {
@@ -198,6 +361,8 @@ func setFieldValue(node *yaml.RNode, fieldRef string, value interface{}) error {
 		if !ok {
 			return fmt.Errorf("value arg containes not expected type")
 		}
+		// the same value may be set to several fields
+		setNode = yaml.NewRNode(copyNode(unwrapDocument(setNode).YNode()))
 	}
 
 	fieldRefs, err := ParseFieldRefs(fieldRef)
@@ -214,109 +379,365 @@ func setFieldValueImpl(node *yaml.RNode, fieldRefs []string, setNode *yaml.RNode
 		return err
 	}
 
-	cn := node
-	for i, p := range path {
-		// index case
-		if cn.YNode().Kind == yaml.SequenceNode {
-			indx, err := seqNodeIndexPath(p)
-			//log.Printf("set: found index %d", indx)
-			if err == nil {
-				content := cn.Content()
-				if indx >= int64(len(content)) {
-					// we don't create by index
-					return fmt.Errorf("index %d is too big", indx)
-				}
-				//log.Printf("building content: %v", content[indx])
-				if i < len(path)-1 {
-					cn = yaml.NewRNode(content[indx])
-					continue
-				}
-				child := yaml.NewRNode(content[indx])
-				if child.YNode().Kind == yaml.ScalarNode {
-					// converting index to brackets format
-					p = fmt.Sprintf("[=%s]", yaml.GetValue(child))
+	return setFieldValuePath(node, path, 0, fieldRefs, setNode)
+}
+
+func setFieldValuePath(cn *yaml.RNode, path []string, i int, fieldRefs []string, setNode *yaml.RNode) error {
+	p := path[i]
+	last := i == len(path)-1
+
+	if cn.YNode().Kind == yaml.SequenceNode {
+		content := cn.Content()
+
+		if p == allElementsPath {
+			for j := range content {
+				var err error
+				if last {
+					err = setSeqElement(cn, j, fieldRefs, setNode)
 				} else {
-					return fmt.Errorf("don't support case when it's necesary to find uniq param")
+					err = setFieldValuePath(yaml.NewRNode(content[j]), path, i+1, fieldRefs, setNode)
+				}
+				if err != nil {
+					return fmt.Errorf("element %d: %w", j, err)
 				}
 			}
+			return nil
 		}
 
-		kind, err := guessNodeKind(i, path, setNode)
-		if err != nil {
-			return fmt.Errorf("wasn't able to guess node kind: %v", err)
+		if p == appendElementPath {
+			kind, err := guessNodeKind(i, path, setNode)
+			if err != nil {
+				return fmt.Errorf("wasn't able to guess node kind: %v", err)
+			}
+			if last && len(fieldRefs) > 1 {
+				kind = yaml.ScalarNode
+			}
+			cn.YNode().Content = append(content, &yaml.Node{Kind: kind})
+			if last {
+				return setSeqElement(cn, len(content), fieldRefs, setNode)
+			}
+			return setFieldValuePath(yaml.NewRNode(cn.YNode().Content[len(content)]), path, i+1, fieldRefs, setNode)
 		}
-		// override to saclar if there is included yaml
-		if i == len(path)-1 && len(fieldRefs) > 1 {
-			kind = yaml.ScalarNode
+
+		// index case
+		indx, err := seqNodeIndexPath(p)
+		if err == nil {
+			j, err := seqNodeIndex(indx, len(content))
+			if err != nil {
+				// we don't create by index - [-] must be used for that
+				return err
+			}
+			if last {
+				return setSeqElement(cn, j, fieldRefs, setNode)
+			}
+			return setFieldValuePath(yaml.NewRNode(content[j]), path, i+1, fieldRefs, setNode)
 		}
 
-		// default case - use lookup
-		cnl, err := cn.Pipe(yaml.Lookup(p))
+		preds, ok, err := parsePredicates(p)
 		if err != nil {
-			return fmt.Errorf("wan't able to lookup %v", err)
+			return err
 		}
-		if cnl == nil {
-			cnl, err = cn.Pipe(yaml.LookupCreate(kind, p))
-			if err != nil {
-				return fmt.Errorf("wan't able to create node %v", err)
+		if ok {
+			j := findPredicates(cn, preds)
+			if j < 0 {
+				// create the element with the key fields
+				elem, err := newPredicatesElement(preds)
+				if err != nil {
+					return fmt.Errorf("wasn't able to create element %s: %w", p, err)
+				}
+				j = len(content)
+				cn.YNode().Content = append(content, elem)
 			}
-			if cnl == nil {
-				return fmt.Errorf("unexpected nil object pointer returned")
+			if last {
+				return setSeqElement(cn, j, fieldRefs, setNode)
 			}
-		} else {
-			if cnl.YNode().Kind != kind {
-				if cnl.YNode().Kind == yaml.ScalarNode && yaml.GetValue(cnl) == "" {
-					//TODO: change
-					return fmt.Errorf("unexpected kind in %v. possible change from emptyScalar isn't implemented", path[:i+1])
-				} else {
-					return fmt.Errorf("unexpected kind in %v", path[:i+1])
-				}
+			return setFieldValuePath(yaml.NewRNode(cn.YNode().Content[j]), path, i+1, fieldRefs, setNode)
+		}
+	}
+
+	kind, err := guessNodeKind(i, path, setNode)
+	if err != nil {
+		return fmt.Errorf("wasn't able to guess node kind: %v", err)
+	}
+	// override to saclar if there is included yaml
+	if last && len(fieldRefs) > 1 {
+		kind = yaml.ScalarNode
+	}
+
+	// default case - use lookup
+	cnl, err := cn.Pipe(yaml.Lookup(p))
+	if err != nil {
+		return fmt.Errorf("wan't able to lookup %v", err)
+	}
+	if cnl == nil {
+		cnl, err = cn.Pipe(yaml.LookupCreate(kind, p))
+		if err != nil {
+			return fmt.Errorf("wan't able to create node %v", err)
+		}
+		if cnl == nil {
+			return fmt.Errorf("unexpected nil object pointer returned")
+		}
+	} else {
+		if cnl.YNode().Kind != kind {
+			if cnl.YNode().Kind == yaml.ScalarNode && yaml.GetValue(cnl) == "" {
+				//TODO: change
+				return fmt.Errorf("unexpected kind in %v. possible change from emptyScalar isn't implemented", path[:i+1])
+			} else {
+				return fmt.Errorf("unexpected kind in %v", path[:i+1])
 			}
 		}
+	}
 
-		cnp := cn
-		cn = cnl
+	if !last {
+		return setFieldValuePath(cnl, path, i+1, fieldRefs, setNode)
+	}
 
-		if i == len(path)-1 {
-			if len(fieldRefs) > 1 {
-				includedNode, err := yaml.Parse(yaml.GetValue(cn))
-				if err != nil {
-					return fmt.Errorf("wan't able to parse %s", yaml.GetValue(cn))
-				}
-				err = setFieldValueImpl(includedNode, fieldRefs[1:], setNode)
-				if err != nil {
-					return fmt.Errorf("wan't able to setFieldValueImpl %v", err)
-				}
-				s, err := includedNode.String()
-				if err != nil {
-					return fmt.Errorf("wan't able to convert to string %v", err)
-				}
-				err = cn.PipeE(yaml.FieldSetter{StringValue: s})
+	if len(fieldRefs) > 1 {
+		return setIncludedFieldValue(cnl, fieldRefs[1:], setNode)
+	}
+
+	if cn.YNode().Kind == yaml.MappingNode {
+		err = cn.PipeE(yaml.FieldSetter{Name: p, Value: setNode})
+		if err != nil {
+			return fmt.Errorf("wan't able to set map: %v", err)
+		}
+	} else { /*opposite is only yaml.SequenceNode */
+		// we need to delete the found element
+		// and set the new one instead
+		k, v, err := yaml.SplitIndexNameValue(p)
+		if err != nil {
+			return fmt.Errorf("can't get kv %s", p)
+		}
+
+		err = cn.PipeE(yaml.ElementSetter{Element: setNode.YNode(), Key: k, Value: v})
+		if err != nil {
+			return fmt.Errorf("wan't able to set seq: %v", err)
+		}
+	}
+	return nil
+}
+
+// expandFieldRef replaces [*] in the path of fieldRef with the indexes
+// of the existing elements and returns the fieldref for each of them.
+// [*] in the included documents isn't expanded
+func expandFieldRef(node *yaml.RNode, fieldRef string) ([]string, error) {
+	fieldRefs, err := ParseFieldRefs(fieldRef)
+	if err != nil {
+		return nil, err
+	}
+	path, err := ParseFieldRef(fieldRefs[0])
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, p := range path {
+		found = found || p == allElementsPath
+	}
+	if !found {
+		return []string{fieldRef}, nil
+	}
+
+	out := []string{}
+	var expand func(cn *yaml.RNode, i int, prefix []string) error
+	expand = func(cn *yaml.RNode, i int, prefix []string) error {
+		if cn == nil || i == len(path) {
+			// the rest of path is created or reported by setter
+			rest := append(append([]string{}, prefix...), path[i:]...)
+			out = append(out, joinFieldRefs(append([]string{joinFieldRef(rest)}, fieldRefs[1:]...)))
+			return nil
+		}
+		p := path[i]
+		if p == allElementsPath && cn.YNode().Kind == yaml.SequenceNode {
+			for j, e := range cn.Content() {
+				err := expand(yaml.NewRNode(e), i+1, append(append([]string{}, prefix...), fmt.Sprintf("[%d]", j)))
 				if err != nil {
-					return fmt.Errorf("wan't able to set back: %v", err)
+					return err
 				}
-			} else {
-				if cnp.YNode().Kind == yaml.MappingNode {
-					err = cnp.PipeE(yaml.FieldSetter{Name: p, Value: setNode})
-					if err != nil {
-						return fmt.Errorf("wan't able to set map: %v", err)
-					}
-				} else { /*opposite is only yaml.SequenceNode */
-					// we need to delete the found element
-					// and set the new one instead
-					k, v, err := yaml.SplitIndexNameValue(p)
-					if err != nil {
-						return fmt.Errorf("can't get kv %s", p)
-					}
+			}
+			return nil
+		}
+		nodes, _, err := lookupFieldNodes(cn, []string{p})
+		if err != nil {
+			return err
+		}
+		var next *yaml.RNode
+		if len(nodes) > 0 {
+			next = nodes[0]
+		}
+		return expand(next, i+1, append(append([]string{}, prefix...), p))
+	}
+	err = expand(node, 0, []string{})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-					err = cnp.PipeE(yaml.ElementSetter{Element: setNode.YNode(), Key: k, Value: v})
-					if err != nil {
-						return fmt.Errorf("wan't able to set seq: %v", err)
-					}
+// joinFieldRef builds the fieldref from the path elements
+func joinFieldRef(path []string) string {
+	var out bytes.Buffer
+	for _, p := range path {
+		if p != "" && p[0] == '[' {
+			out.WriteString(p)
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteRune('.')
+		}
+		if strings.ContainsAny(p, ".[|\"'") {
+			quote := "\""
+			if strings.Contains(p, quote) {
+				quote = "'"
+			}
+			out.WriteString(quote + p + quote)
+			continue
+		}
+		out.WriteString(p)
+	}
+	return out.String()
+}
+
+// joinFieldRefs builds the fieldref from the fieldrefs of the included documents
+func joinFieldRefs(fieldRefs []string) string {
+	return strings.Join(fieldRefs, "|")
+}
+
+// deleteFieldValue deletes the field from node.
+// The fields of the included documents can't be deleted
+func deleteFieldValue(node *yaml.RNode, fieldRef string) error {
+	fieldRefs, err := ParseFieldRefs(fieldRef)
+	if err != nil {
+		return err
+	}
+	if len(fieldRefs) > 1 {
+		return fmt.Errorf("fields of included documents can't be deleted")
+	}
+	path, err := ParseFieldRef(fieldRefs[0])
+	if err != nil {
+		return err
+	}
+	p := path[len(path)-1]
+	if p == "" {
+		return fmt.Errorf("empty fieldref")
+	}
+
+	parents, _, err := lookupFieldNodes(node, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	for _, cn := range parents {
+		switch cn.YNode().Kind {
+		case yaml.MappingNode:
+			_, err := cn.Pipe(yaml.Clear(p))
+			if err != nil {
+				return err
+			}
+		case yaml.SequenceNode:
+			js, err := seqElementIndexes(cn, p)
+			if err != nil {
+				return err
+			}
+			content := cn.YNode().Content
+			for k := len(js) - 1; k >= 0; k-- {
+				content = append(content[:js[k]], content[js[k]+1:]...)
+			}
+			cn.YNode().Content = content
+		}
+	}
+	return nil
+}
+
+// seqElementIndexes returns the indexes of the elements of seq selected by p
+func seqElementIndexes(seq *yaml.RNode, p string) ([]int, error) {
+	content := seq.Content()
+	if p == allElementsPath {
+		js := make([]int, 0, len(content))
+		for j := range content {
+			js = append(js, j)
+		}
+		return js, nil
+	}
+	if indx, err := seqNodeIndexPath(p); err == nil {
+		j, err := seqNodeIndex(indx, len(content))
+		if err != nil {
+			return nil, err
+		}
+		return []int{j}, nil
+	}
+	preds, ok, err := parsePredicates(p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		k, v, err := yaml.SplitIndexNameValue(p)
+		if err != nil {
+			return nil, err
+		}
+		if k == "" {
+			// scalar element
+			for j, elem := range content {
+				if elem.Kind == yaml.ScalarNode && elem.Value == v {
+					return []int{j}, nil
 				}
 			}
+			return nil, nil
 		}
+		preds = []predicate{{path: []string{k}, value: v}}
+	}
+	if j := findPredicates(seq, preds); j >= 0 {
+		return []int{j}, nil
+	}
+	return nil, nil
+}
+
+// setSeqElement sets the element j of sequence seq
+func setSeqElement(seq *yaml.RNode, j int, fieldRefs []string, setNode *yaml.RNode) error {
+	if len(fieldRefs) > 1 {
+		return setIncludedFieldValue(yaml.NewRNode(seq.YNode().Content[j]), fieldRefs[1:], setNode)
+	}
+	seq.YNode().Content[j] = copyNode(setNode.YNode())
+	return nil
+}
+
+// setIncludedFieldValue sets the value inside the string of the scalar
+// node cn. The string is decoded and encoded back by codecs in fieldRefs.
+// If there is no codec specified the string is handled as yaml.
+func setIncludedFieldValue(cn *yaml.RNode, fieldRefs []string, setNode *yaml.RNode) error {
+	c, ok := codecs[fieldRefs[0]]
+	if ok {
+		fieldRefs = fieldRefs[1:]
+	} else {
+		c = codecs[YamlCodec]
 	}
 
+	if cn.YNode().Kind != yaml.ScalarNode {
+		return fmt.Errorf("node %v isn't scalar", cn.FieldPath())
+	}
+
+	includedNode, err := c.decode(yaml.GetValue(cn))
+	if err != nil {
+		return fmt.Errorf("wan't able to decode %s: %v", yaml.GetValue(cn), err)
+	}
+
+	switch {
+	case len(fieldRefs) == 0:
+		includedNode = setNode
+	case !c.structured || isCodec(fieldRefs[0]):
+		err = setIncludedFieldValue(includedNode, fieldRefs, setNode)
+	default:
+		err = setFieldValueImpl(includedNode, fieldRefs, setNode)
+	}
+	if err != nil {
+		return fmt.Errorf("wan't able to setFieldValueImpl %v", err)
+	}
+
+	s, err := c.encode(includedNode)
+	if err != nil {
+		return fmt.Errorf("wan't able to encode %v", err)
+	}
+	err = cn.PipeE(yaml.FieldSetter{Value: yaml.NewScalarRNode(s)})
+	if err != nil {
+		return fmt.Errorf("wan't able to set back: %v", err)
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package replacement
 
 import (
+	"strings"
 	"testing"
 
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -40,6 +41,34 @@ func TestParseFieldRef(t *testing.T) {
 			In:  `a.b[2.c`,
 			Err: true,
 		},
+		{
+			In:  `a.b[*].c`,
+			Out: []string{"a", "b", "[*]", "c"},
+		},
+		{
+			In:  `a.b[-1].c`,
+			Out: []string{"a", "b", "[-1]", "c"},
+		},
+		{
+			In:  `a.b[-]`,
+			Out: []string{"a", "b", "[-]"},
+		},
+		{
+			In:  `metadata.annotations."config.kubernetes.io/function"`,
+			Out: []string{"metadata", "annotations", "config.kubernetes.io/function"},
+		},
+		{
+			In:  `metadata.annotations.'config.kubernetes.io/path'.x`,
+			Out: []string{"metadata", "annotations", "config.kubernetes.io/path", "x"},
+		},
+		{
+			In:  `a."b.c[0]"[0]`,
+			Out: []string{"a", "b.c[0]", "[0]"},
+		},
+		{
+			In:  `a."b.c`,
+			Err: true,
+		},
 	}
 	for _, ti := range ts {
 		x, err := ParseFieldRef(ti.In)
@@ -172,6 +201,137 @@ a:
 			InField:     "a.b|c.e|f",
 			ExpectedVal: "innerValue2",
 		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+    d: data1
+  - c: value2
+    d: data2
+`,
+			InField:     "a.b[-1].d",
+			ExpectedVal: "data2",
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+    d: data1
+  - c: value2
+    d: data2
+`,
+			InField:       "a.b[-3].d",
+			ExpectedError: true,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+    d: data1
+  - c: value2
+  - c: value3
+    d: data3
+`,
+			InField: "a.b[*].d",
+			ExpectedVal: `- data1
+- data3
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+`,
+			InField:       "a.b[-]",
+			ExpectedError: true,
+		},
+		{
+			InYaml: `
+metadata:
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: quay.io/aodinokov/replacement-default:v0.0.2
+`,
+			InField:     `metadata.annotations."config.kubernetes.io/function"|container.image`,
+			ExpectedVal: "quay.io/aodinokov/replacement-default:v0.0.2",
+		},
+		{
+			InYaml: `
+data:
+  userData: cnVuY21kOgotIGVjaG8gaGkgJiYgcmVib290Cg==
+`,
+			InField:     "data.userData|base64|yaml|.runcmd[0]",
+			ExpectedVal: "echo hi && reboot",
+		},
+		{
+			InYaml: `
+data:
+  userData: eyJob3N0bmFtZSI6Im5vZGUwMSIsInJ1bmNtZCI6WyJyZWJvb3QiXX0=
+`,
+			InField:     "data.userData|base64|json|hostname",
+			ExpectedVal: "node01",
+		},
+		{
+			InYaml: `
+data:
+  password: aGVsbG8=
+`,
+			InField:     "data.password|base64",
+			ExpectedVal: "hello",
+		},
+		{
+			InYaml: `
+data:
+  config.toml: |
+    [plugins.cri]
+      sandbox_image = "k8s.gcr.io/pause:3.1"
+`,
+			InField:     `data."config.toml"|toml|plugins.cri.sandbox_image`,
+			ExpectedVal: "k8s.gcr.io/pause:3.1",
+		},
+		{
+			InYaml: `
+spec:
+  containers:
+  - name: ironic
+    ports:
+    - containerPort: 80
+      protocol: UDP
+    - containerPort: 80
+      protocol: TCP
+      name: http
+`,
+			InField:     "spec.containers[name=ironic].ports[containerPort=80,protocol=TCP].name",
+			ExpectedVal: "http",
+		},
+		{
+			InYaml: `
+volumeClaimTemplates:
+- metadata:
+    name: logs
+  spec:
+    size: 1Gi
+- metadata:
+    name: data
+  spec:
+    size: 10Gi
+`,
+			InField:     "volumeClaimTemplates[metadata.name=data].spec.size",
+			ExpectedVal: "10Gi",
+		},
+		{
+			InYaml: `
+a:
+- b: c
+`,
+			InField:       "a[b=c,=d]",
+			ExpectedError: true,
+		},
 	}
 
 	for _, ti := range ts {
@@ -188,6 +348,17 @@ a:
 		if err != nil {
 			t.Errorf("didn't expect error for field: %s yaml %s: %v", ti.InField, ti.InYaml, err)
 		}
+		if err == nil && ti.ExpectedError {
+			t.Errorf("expected error for field: %s yaml %s", ti.InField, ti.InYaml)
+			continue
+		}
+		if rn, ok := val.(*yaml.RNode); ok {
+			val, err = rn.String()
+			if err != nil {
+				t.Errorf("got unexpected error converting node for field: %s yaml %s: %v", ti.InField, ti.InYaml, err)
+				continue
+			}
+		}
 		if val != ti.ExpectedVal {
 			t.Errorf("unexpected value %s for field: %s yaml %s. Expected %s",
 				val, ti.InField, ti.InYaml, ti.ExpectedVal)
@@ -275,6 +446,209 @@ a:
     c: |
       d:
         e: newvalue
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+  - c: value2
+`,
+			InField:       "a.b[*].d",
+			InValueString: "newvalue",
+			ExpectedYaml: `
+a:
+  b:
+  - c: value1
+    d: newvalue
+  - c: value2
+    d: newvalue
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - x
+  - y
+  - z
+`,
+			InField:       "a.b[-1]",
+			InValueString: "newvalue",
+			ExpectedYaml: `
+a:
+  b:
+  - x
+  - y
+  - newvalue
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+  - c: value2
+`,
+			InField: "a.b[-1]",
+			InValueYaml: `
+c: newvalue
+d: newdata
+`,
+			ExpectedYaml: `
+a:
+  b:
+  - c: value1
+  - c: newvalue
+    d: newdata
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - x
+`,
+			InField:       "a.b[-]",
+			InValueString: "y",
+			ExpectedYaml: `
+a:
+  b:
+  - x
+  - y
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - c: value1
+`,
+			InField:       "a.b[-].c",
+			InValueString: "value2",
+			ExpectedYaml: `
+a:
+  b:
+  - c: value1
+  - c: value2
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+  - x
+`,
+			InField:       "a.b[3]",
+			InValueString: "y",
+			ExpectedError: true,
+		},
+		{
+			InYaml: `
+metadata:
+  annotations:
+    config.kubernetes.io/path: a.yaml
+`,
+			InField:       `metadata.annotations."config.kubernetes.io/path"`,
+			InValueString: "b.yaml",
+			ExpectedYaml: `
+metadata:
+  annotations:
+    config.kubernetes.io/path: b.yaml
+`,
+		},
+		{
+			InYaml: `
+data:
+  userData: cnVuY21kOgotIGVjaG8gaGkgJiYgcmVib290Cg==
+`,
+			InField:       "data.userData|base64|yaml|runcmd[-]",
+			InValueString: "poweroff",
+			ExpectedYaml: `
+data:
+  userData: cnVuY21kOgotIGVjaG8gaGkgJiYgcmVib290Ci0gcG93ZXJvZmYK
+`,
+		},
+		{
+			InYaml: `
+data:
+  userData: eyJob3N0bmFtZSI6Im5vZGUwMSIsInJ1bmNtZCI6WyJyZWJvb3QiXX0=
+`,
+			InField:       "data.userData|base64|json|hostname",
+			InValueString: "node02",
+			ExpectedYaml: `
+data:
+  userData: eyJob3N0bmFtZSI6Im5vZGUwMiIsInJ1bmNtZCI6WyJyZWJvb3QiXX0=
+`,
+		},
+		{
+			InYaml: `
+data:
+  username: YWRtaW4=
+`,
+			InField:       "data.password|base64",
+			InValueString: "hello",
+			ExpectedYaml: `
+data:
+  username: YWRtaW4=
+  password: aGVsbG8=
+`,
+		},
+		{
+			InYaml: `
+stringData:
+  userData: |
+    {"hostname": "node01"}
+`,
+			InField:       "stringData.userData|json|hostname",
+			InValueString: "node02",
+			ExpectedYaml: `
+stringData:
+  userData: |-
+    {"hostname":"node02"}
+`,
+		},
+		{
+			InYaml: `
+spec:
+  containers:
+  - name: ironic
+    env:
+    - name: PROVISIONING_IP
+      value: old
+    - name: PROVISIONING_IP
+      valueFrom: secret
+`,
+			InField:       "spec.containers[name=ironic].env[name=PROVISIONING_IP,value=old].value",
+			InValueString: "10.0.0.1",
+			ExpectedYaml: `
+spec:
+  containers:
+  - name: ironic
+    env:
+    - name: PROVISIONING_IP
+      value: 10.0.0.1
+    - name: PROVISIONING_IP
+      valueFrom: secret
+`,
+		},
+		{
+			InYaml: `
+spec:
+  replicas: 1
+`,
+			InField:       "spec.volumeClaimTemplates[metadata.name=data,spec.storageClassName=fast].spec.size",
+			InValueString: "10Gi",
+			ExpectedYaml: `
+spec:
+  replicas: 1
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+    spec:
+      storageClassName: fast
+      size: 10Gi
 `,
 		},
 	}
@@ -311,6 +685,10 @@ a:
 		if err != nil {
 			t.Errorf("didn't expect error for field: %s yaml %s: %v", ti.InField, ti.InYaml, err)
 		}
+		if err == nil && ti.ExpectedError {
+			t.Errorf("expected error for field: %s yaml %s", ti.InField, ti.InYaml)
+			continue
+		}
 		resYaml, err := node.String()
 		if err != nil {
 			t.Errorf("got unexpected error converting node back for inYaml %s: %v", ti.InYaml, err)
@@ -321,3 +699,36 @@ a:
 		}
 	}
 }
+
+func TestExpandFieldRef(t *testing.T) {
+	node, err := yaml.Parse(`
+a:
+- b:
+    "x.y": 1
+- b:
+    "x.y": 2
+- c: 3
+`)
+	if err != nil {
+		t.Fatalf("can't parse: %v", err)
+	}
+	ts := []struct {
+		InField     string
+		ExpectedOut []string
+	}{
+		{InField: "a[0].b", ExpectedOut: []string{"a[0].b"}},
+		{InField: `a[*].b."x.y"`, ExpectedOut: []string{`a[0].b."x.y"`, `a[1].b."x.y"`, `a[2].b."x.y"`}},
+		{InField: "a[*].b|c[*]", ExpectedOut: []string{"a[0].b|c[*]", "a[1].b|c[*]", "a[2].b|c[*]"}},
+		{InField: "d[*].e", ExpectedOut: []string{"d[*].e"}},
+	}
+	for _, ti := range ts {
+		out, err := expandFieldRef(node, ti.InField)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", ti.InField, err)
+			continue
+		}
+		if strings.Join(out, " ") != strings.Join(ti.ExpectedOut, " ") {
+			t.Errorf("for %s expected %v, got %v", ti.InField, ti.ExpectedOut, out)
+		}
+	}
+}
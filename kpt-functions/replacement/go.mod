@@ -3,12 +3,11 @@ module github.com/aodinokov/noctl-airship-poc/kpt-functions/replacement
 go 1.14
 
 require (
-	github.com/Masterminds/goutils v1.1.0 // indirect
-	github.com/Masterminds/semver v1.5.0 // indirect
-	github.com/Masterminds/sprig v2.22.0+incompatible
-	github.com/huandu/xstrings v1.3.2 // indirect
-	github.com/mitchellh/copystructure v1.0.0 // indirect
+	github.com/BurntSushi/toml v0.3.1
+	github.com/aodinokov/noctl-airship-poc/kpt-functions/templater v0.0.0-20261019142509-f085c8141f51
+	golang.org/x/crypto v0.0.0-20190923035154-9ee001bba392 // indirect
 	k8s.io/apimachinery v0.17.0
-	sigs.k8s.io/kustomize/api v0.5.1
 	sigs.k8s.io/kustomize/kyaml v0.4.1
 )
+
+//replace github.com/aodinokov/noctl-airship-poc/kpt-functions/templater => ../templater
@@ -0,0 +1,86 @@
+package replacement
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// coreGroup can be used in Group to select only the resources
+	// from the legacy core group, e.g. `apiVersion: v1`
+	coreGroup = "core"
+)
+
+// GvkFilter selects the resources by group, version and kind.
+// Group and version are taken from apiVersion field.
+// Version may contain shell-like wildcards, e.g. v1alpha*
+type GvkFilter struct {
+	Group   string `yaml:"group,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Kind    string `yaml:"kind,omitempty"`
+}
+
+// splitAPIVersion returns group and version of apiVersion.
+// Resources from the core group have only version, e.g. `v1`
+func splitAPIVersion(apiVersion string) (string, string) {
+	i := strings.LastIndex(apiVersion, "/")
+	if i < 0 {
+		return "", apiVersion
+	}
+	return apiVersion[:i], apiVersion[i+1:]
+}
+
+func (f GvkFilter) Match(node *yaml.RNode) (bool, error) {
+	meta, err := node.GetMeta()
+	if err == yaml.ErrMissingMetadata {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if f.Kind != "" && meta.Kind != f.Kind {
+		return false, nil
+	}
+	group, version := splitAPIVersion(meta.APIVersion)
+
+	if f.Group != "" {
+		if f.Group == coreGroup {
+			if group != "" {
+				return false, nil
+			}
+		} else if f.Group != group {
+			return false, nil
+		}
+	}
+
+	if f.Version != "" {
+		ok, err := path.Match(f.Version, version)
+		if err != nil {
+			return false, fmt.Errorf("incorrect version pattern %s: %w", f.Version, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f GvkFilter) Filter(input []*yaml.RNode) ([]*yaml.RNode, error) {
+	var output kio.ResourceNodeSlice
+
+	for i := range input {
+		ok, err := f.Match(input[i])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			output = append(output, input[i])
+		}
+	}
+	return output, nil
+}
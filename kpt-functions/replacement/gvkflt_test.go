@@ -0,0 +1,188 @@
+package replacement
+
+import (
+	"bytes"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+
+	"testing"
+)
+
+func TestGvkFilter(t *testing.T) {
+	inNodes := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: target-cluster-ca
+---
+apiVersion: cluster.x-k8s.io/v1alpha3
+kind: Cluster
+metadata:
+  name: target-cluster
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1alpha3
+kind: Metal3Cluster
+metadata:
+  name: target-cluster
+---
+apiVersion: controlplane.cluster.x-k8s.io/v1alpha3
+kind: KubeadmControlPlane
+metadata:
+  name: cluster-controlplane
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node01
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ironic
+`
+	ts := []struct {
+		InFilter GvkFilter
+		OutNames []string
+		OutError bool
+	}{
+		{
+			InFilter: GvkFilter{Version: "v1"},
+			OutNames: []string{"target-cluster-ca", "ironic"},
+		},
+		{
+			InFilter: GvkFilter{Group: "core"},
+			OutNames: []string{"target-cluster-ca"},
+		},
+		{
+			InFilter: GvkFilter{Group: "core", Version: "v1", Kind: "Secret"},
+			OutNames: []string{"target-cluster-ca"},
+		},
+		{
+			InFilter: GvkFilter{Group: "cluster.x-k8s.io"},
+			OutNames: []string{"target-cluster"},
+		},
+		{
+			InFilter: GvkFilter{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "Metal3Cluster"},
+			OutNames: []string{"target-cluster"},
+		},
+		{
+			InFilter: GvkFilter{Version: "v1alpha3"},
+			OutNames: []string{"target-cluster", "target-cluster", "cluster-controlplane"},
+		},
+		{
+			InFilter: GvkFilter{Version: "v1alpha*"},
+			OutNames: []string{"target-cluster", "target-cluster", "cluster-controlplane", "node01"},
+		},
+		{
+			InFilter: GvkFilter{Group: "metal3.io", Version: "v1alpha*", Kind: "BareMetalHost"},
+			OutNames: []string{"node01"},
+		},
+		{
+			InFilter: GvkFilter{Group: "metal3.io", Version: "v1beta*"},
+			OutNames: []string{},
+		},
+		{
+			InFilter: GvkFilter{Kind: "Deployment"},
+			OutNames: []string{"ironic"},
+		},
+		{
+			InFilter: GvkFilter{Version: "v1alpha["},
+			OutError: true,
+		},
+	}
+
+	for _, ti := range ts {
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(inNodes)}).Read()
+		if err != nil {
+			t.Errorf("can't read nodes: %v", err)
+			continue
+		}
+		out, err := ti.InFilter.Filter(nodes)
+		if err != nil {
+			if !ti.OutError {
+				t.Errorf("got unexpected error when filtering with %v: %v", ti.InFilter, err)
+			}
+			continue
+		}
+		if ti.OutError {
+			t.Errorf("expected error when filtering with %v", ti.InFilter)
+			continue
+		}
+
+		names := []string{}
+		for _, n := range out {
+			meta, err := n.GetMeta()
+			if err != nil {
+				t.Errorf("can't get meta: %v", err)
+				continue
+			}
+			names = append(names, meta.Name)
+		}
+		eq := len(names) == len(ti.OutNames)
+		for i := 0; eq && i < len(names); i++ {
+			eq = names[i] == ti.OutNames[i]
+		}
+		if !eq {
+			t.Errorf("for %v expected %v, got %v", ti.InFilter, ti.OutNames, names)
+		}
+	}
+}
+
+func TestGvkFilterPackage(t *testing.T) {
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath: "../../packages/cluster_types/nc3prime/manifests/type/target_controlplane",
+	}).Read()
+	if err != nil {
+		t.Fatalf("can't read package: %v", err)
+	}
+
+	ts := []struct {
+		InFilter GvkFilter
+		OutNames []string
+	}{
+		{
+			InFilter: GvkFilter{Group: "core", Kind: "Secret"},
+			OutNames: []string{"target-cluster-ca"},
+		},
+		{
+			InFilter: GvkFilter{Group: "cluster.x-k8s.io", Version: "v1alpha3"},
+			OutNames: []string{"target-cluster"},
+		},
+		{
+			InFilter: GvkFilter{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha*"},
+			OutNames: []string{"target-cluster", "cluster-controlplane"},
+		},
+		{
+			InFilter: GvkFilter{Group: "controlplane.cluster.x-k8s.io", Kind: "KubeadmControlPlane"},
+			OutNames: []string{"cluster-controlplane"},
+		},
+		{
+			InFilter: GvkFilter{Kind: "Metal3MachineTemplate"},
+			OutNames: []string{"cluster-controlplane"},
+		},
+	}
+
+	for _, ti := range ts {
+		out, err := ti.InFilter.Filter(nodes)
+		if err != nil {
+			t.Errorf("got unexpected error when filtering with %v: %v", ti.InFilter, err)
+			continue
+		}
+		names := []string{}
+		for _, n := range out {
+			meta, err := n.GetMeta()
+			if err != nil {
+				t.Errorf("can't get meta: %v", err)
+				continue
+			}
+			names = append(names, meta.Name)
+		}
+		eq := len(names) == len(ti.OutNames)
+		for i := 0; eq && i < len(names); i++ {
+			eq = names[i] == ti.OutNames[i]
+		}
+		if !eq {
+			t.Errorf("for %v expected %v, got %v", ti.InFilter, ti.OutNames, names)
+		}
+	}
+}
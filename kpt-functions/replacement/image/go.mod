@@ -7,4 +7,9 @@ require (
 	sigs.k8s.io/kustomize/kyaml v0.4.1
 )
 
-//replace github.com/aodinokov/noctl-airship-poc/kpt-functions/replacement => ../
+// the image is built from the source tree, including the templater
+// funcs used by the replacement function
+replace (
+	github.com/aodinokov/noctl-airship-poc/kpt-functions/replacement => ../
+	github.com/aodinokov/noctl-airship-poc/kpt-functions/templater => ../../templater
+)
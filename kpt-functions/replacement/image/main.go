@@ -26,7 +26,25 @@ func main() {
 			return err
 		}
 
-		return fn.Exec(resourceList.Items)
+		resourceList.Items, err = fn.Filter(resourceList.Items)
+		if err != nil {
+			return err
+		}
+		if cfg.Report != "" {
+			report, err := fn.ReportNode()
+			if err != nil {
+				return err
+			}
+			resourceList.Items = append(resourceList.Items, report)
+		}
+		if len(fn.Warnings) > 0 {
+			resourceList.Result = &framework.Result{Name: "replacement"}
+			for _, w := range fn.Warnings {
+				resourceList.Result.Items = append(resourceList.Result.Items,
+					framework.Item{Message: w, Severity: framework.Warning})
+			}
+		}
+		return nil
 	})
 
 	if err := cmd.Execute(); err != nil {
@@ -22,14 +22,17 @@ func (f LabelFilter) Filter(input []*yaml.RNode) ([]*yaml.RNode, error) {
 			return nil, err
 		}
 
-		if val == nil || yaml.GetValue(val) == "" {
+		if val == nil {
 			continue
 		}
 
-		lset, err := labels.ConvertSelectorToLabelsMap(yaml.GetValue(val))
+		lset, err := labelsSet(val)
 		if err != nil {
 			return nil, err
 		}
+		if len(lset) == 0 {
+			continue
+		}
 
 		s, err := labels.Parse(f.Selector)
 		if err != nil {
@@ -42,3 +45,20 @@ func (f LabelFilter) Filter(input []*yaml.RNode) ([]*yaml.RNode, error) {
 	}
 	return output, nil
 }
+
+// labelsSet reads the labels from the map, e.g. metadata.labels,
+// or from the string like "x=y,z=a"
+func labelsSet(val *yaml.RNode) (labels.Set, error) {
+	if val.YNode().Kind != yaml.MappingNode {
+		return labels.ConvertSelectorToLabelsMap(yaml.GetValue(val))
+	}
+	lset := labels.Set{}
+	err := val.VisitFields(func(n *yaml.MapNode) error {
+		lset[yaml.GetValue(n.Key)] = yaml.GetValue(n.Value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lset, nil
+}
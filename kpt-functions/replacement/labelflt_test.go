@@ -35,6 +35,32 @@ kind: test
 metadata:
   name: testname2
   labels: "x=r,z=xxx"
+`,
+		},
+		{
+			InNodes: `
+kind: test
+metadata:
+  name: testname1
+  labels:
+    x: y
+---
+kind: test
+metadata:
+  name: testname2
+  labels:
+    x: r
+    z: xxx
+`,
+			InPath:     []string{"metadata", "labels"},
+			InSelector: "x in (r), z in (xxx)",
+			OutNodes: `
+kind: test
+metadata:
+  name: testname2
+  labels:
+    x: r
+    z: xxx
 `,
 		},
 	}
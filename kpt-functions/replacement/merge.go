@@ -0,0 +1,195 @@
+package replacement
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Merge strategies that can be set in Target.MergeStrategy
+const (
+	// ReplaceMergeStrategy replaces the target field with the value
+	ReplaceMergeStrategy = "replace"
+	// StrategicMergeStrategy merges maps recursively, merges list
+	// elements with the same value of MergeKey and adds the missing
+	// elements to the lists
+	StrategicMergeStrategy = "strategic-merge"
+	// AppendUniqueMergeStrategy appends to the target list the
+	// elements that it doesn't contain yet
+	AppendUniqueMergeStrategy = "append-unique"
+	// DeepMergeStrategy merges maps recursively, lists are replaced
+	DeepMergeStrategy = "deep-merge"
+
+	defaultMergeKey = "name"
+)
+
+func isValidMergeStrategy(s string) bool {
+	switch s {
+	case "", ReplaceMergeStrategy, StrategicMergeStrategy, AppendUniqueMergeStrategy, DeepMergeStrategy:
+		return true
+	}
+	return false
+}
+
+// mergeValueForTarget merges value with the current value of
+// the field fieldRef in node according to the strategy.
+// The current value isn't modified - the merged copy is returned.
+func mergeValueForTarget(node *yaml.RNode, fieldRef string, value interface{}, strategy, key string) (interface{}, error) {
+	if strategy == "" || strategy == ReplaceMergeStrategy {
+		return value, nil
+	}
+
+	vnode, ok := value.(*yaml.RNode)
+	if !ok || vnode.YNode().Kind == yaml.ScalarNode {
+		return nil, fmt.Errorf("%s can be used only with non-scalar values", strategy)
+	}
+
+	fieldRefs, err := ParseFieldRefs(fieldRef)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := getFieldValueImpl(node, fieldRefs)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err != nil || cur == nil {
+		// nothing to merge with
+		return value, nil
+	}
+
+	if key == "" {
+		key = defaultMergeKey
+	}
+
+	merged, err := mergeNodes(copyNode(cur.YNode()), unwrapDocument(vnode).YNode(), strategy, key)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.NewRNode(merged), nil
+}
+
+// mergeNodes merges src into dst and returns the result
+func mergeNodes(dst, src *yaml.Node, strategy, key string) (*yaml.Node, error) {
+	if dst.Kind != src.Kind {
+		return nil, fmt.Errorf("can't merge nodes of different kinds")
+	}
+
+	switch dst.Kind {
+	case yaml.MappingNode:
+		if strategy == AppendUniqueMergeStrategy {
+			return nil, fmt.Errorf("%s can be used only with lists", strategy)
+		}
+		return mergeMaps(dst, src, strategy, key)
+	case yaml.SequenceNode:
+		switch strategy {
+		case AppendUniqueMergeStrategy:
+			return appendUnique(dst, src)
+		case StrategicMergeStrategy:
+			return mergeLists(dst, src, strategy, key)
+		}
+	}
+	return copyNode(src), nil
+}
+
+func mergeMaps(dst, src *yaml.Node, strategy, key string) (*yaml.Node, error) {
+	for i := 0; i < len(src.Content); i += 2 {
+		k, v := src.Content[i], src.Content[i+1]
+
+		found := false
+		for j := 0; j < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != k.Value {
+				continue
+			}
+			found = true
+
+			dv := dst.Content[j+1]
+			if dv.Kind != v.Kind || v.Kind == yaml.ScalarNode {
+				dst.Content[j+1] = copyNode(v)
+				break
+			}
+			m, err := mergeNodes(dv, v, strategy, key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k.Value, err)
+			}
+			dst.Content[j+1] = m
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, copyNode(k), copyNode(v))
+		}
+	}
+	return dst, nil
+}
+
+func mergeLists(dst, src *yaml.Node, strategy, key string) (*yaml.Node, error) {
+	for _, v := range src.Content {
+		if v.Kind != yaml.MappingNode {
+			if indexOfNode(dst, v) < 0 {
+				dst.Content = append(dst.Content, copyNode(v))
+			}
+			continue
+		}
+
+		kv := yaml.NewRNode(v).Field(key)
+		if kv == nil {
+			dst.Content = append(dst.Content, copyNode(v))
+			continue
+		}
+
+		found := false
+		for j, dv := range dst.Content {
+			if dv.Kind != yaml.MappingNode {
+				continue
+			}
+			dkv := yaml.NewRNode(dv).Field(key)
+			if dkv == nil || yaml.GetValue(dkv.Value) != yaml.GetValue(kv.Value) {
+				continue
+			}
+			found = true
+
+			m, err := mergeNodes(dv, v, strategy, key)
+			if err != nil {
+				return nil, fmt.Errorf("[%s=%s]: %w", key, yaml.GetValue(kv.Value), err)
+			}
+			dst.Content[j] = m
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, copyNode(v))
+		}
+	}
+	return dst, nil
+}
+
+func appendUnique(dst, src *yaml.Node) (*yaml.Node, error) {
+	for _, v := range src.Content {
+		if indexOfNode(dst, v) < 0 {
+			dst.Content = append(dst.Content, copyNode(v))
+		}
+	}
+	return dst, nil
+}
+
+// indexOfNode returns the index of the element of seq
+// that is equal to n or -1 if there is no such element
+func indexOfNode(seq, n *yaml.Node) int {
+	for i, e := range seq.Content {
+		if nodesEqual(e, n) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nodesEqual compares nodes ignoring styles and comments
+func nodesEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package replacement
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestMergeValueForTarget(t *testing.T) {
+	ts := []struct {
+		InYaml       string
+		InField      string
+		InValueYaml  string
+		InStrategy   string
+		InKey        string
+		ExpectedYaml string
+		ExpectedErr  bool
+	}{
+		{
+			InYaml: `
+a:
+  b:
+    c: 1
+    d: 2
+`,
+			InField: "a.b",
+			InValueYaml: `
+d: 3
+e: 4
+`,
+			InStrategy: ReplaceMergeStrategy,
+			ExpectedYaml: `
+d: 3
+e: 4
+`,
+		},
+		{
+			InYaml: `
+a:
+  b:
+    c: 1
+    d:
+      x: 1
+      l:
+      - 1
+`,
+			InField: "a.b",
+			InValueYaml: `
+d:
+  y: 2
+  l:
+  - 2
+e: 4
+`,
+			InStrategy: DeepMergeStrategy,
+			ExpectedYaml: `
+c: 1
+d:
+  x: 1
+  l:
+  - 2
+  y: 2
+e: 4
+`,
+		},
+		{
+			InYaml: `
+spec:
+  networks:
+  - id: oam-ipv4
+    type: ipv4
+    link: oam
+  - id: pxe-ipv4
+    type: ipv4
+    link: pxe
+`,
+			InField: "spec.networks",
+			InValueYaml: `
+- id: oam-ipv4
+  link: bond0
+- id: storage-ipv4
+  type: ipv4
+  link: storage
+`,
+			InStrategy: StrategicMergeStrategy,
+			InKey:      "id",
+			ExpectedYaml: `
+- id: oam-ipv4
+  type: ipv4
+  link: bond0
+- id: pxe-ipv4
+  type: ipv4
+  link: pxe
+- id: storage-ipv4
+  type: ipv4
+  link: storage
+`,
+		},
+		{
+			InYaml: `
+spec:
+  containers:
+  - name: ironic
+    env:
+    - name: A
+      value: a
+`,
+			InField: "spec.containers",
+			InValueYaml: `
+- name: ironic
+  env:
+  - name: B
+    value: b
+`,
+			InStrategy: StrategicMergeStrategy,
+			ExpectedYaml: `
+- name: ironic
+  env:
+  - name: A
+    value: a
+  - name: B
+    value: b
+`,
+		},
+		{
+			InYaml: `
+spec:
+  ntp:
+  - 0.pool.ntp.org
+  - 1.pool.ntp.org
+`,
+			InField: "spec.ntp",
+			InValueYaml: `
+- 1.pool.ntp.org
+- 2.pool.ntp.org
+`,
+			InStrategy: AppendUniqueMergeStrategy,
+			ExpectedYaml: `
+- 0.pool.ntp.org
+- 1.pool.ntp.org
+- 2.pool.ntp.org
+`,
+		},
+		{
+			InYaml: `
+spec:
+  ntp:
+    server: 0.pool.ntp.org
+`,
+			InField: "spec.ntp",
+			InValueYaml: `
+server: 1.pool.ntp.org
+`,
+			InStrategy:  AppendUniqueMergeStrategy,
+			ExpectedErr: true,
+		},
+		{
+			InYaml: `
+spec:
+  ntp:
+    server: 0.pool.ntp.org
+`,
+			InField: "spec.ntp",
+			InValueYaml: `
+- 1.pool.ntp.org
+`,
+			InStrategy:  DeepMergeStrategy,
+			ExpectedErr: true,
+		},
+		{
+			InYaml: `
+spec: {}
+`,
+			InField: "spec.ntp",
+			InValueYaml: `
+- 1.pool.ntp.org
+`,
+			InStrategy: AppendUniqueMergeStrategy,
+			ExpectedYaml: `
+- 1.pool.ntp.org
+`,
+		},
+	}
+
+	for _, ti := range ts {
+		node, err := yaml.Parse(ti.InYaml)
+		if err != nil {
+			t.Errorf("wasn't able to parse inYaml %s: %v, trying to continue", ti.InYaml, err)
+			continue
+		}
+		value, err := yaml.Parse(ti.InValueYaml)
+		if err != nil {
+			t.Errorf("wasn't able to parse value yaml %s, %v", ti.InValueYaml, err)
+			continue
+		}
+		v, err := mergeValueForTarget(node, ti.InField, value, ti.InStrategy, ti.InKey)
+		if err != nil {
+			if !ti.ExpectedErr {
+				t.Errorf("unexpected error merging %s into %s: %v", ti.InValueYaml, ti.InField, err)
+			}
+			continue
+		}
+		if ti.ExpectedErr {
+			t.Errorf("expected error merging %s into %s", ti.InValueYaml, ti.InField)
+			continue
+		}
+		out, err := v.(*yaml.RNode).String()
+		if err != nil {
+			t.Errorf("can't convert node to string: %v", err)
+			continue
+		}
+		if out != ti.ExpectedYaml[1:] {
+			t.Errorf("merging into %s expected \n%s, got \n%s", ti.InField, ti.ExpectedYaml[1:], out)
+		}
+		// check that the original wasn't modified
+		orig, err := node.String()
+		if err != nil {
+			t.Errorf("can't convert node to string: %v", err)
+			continue
+		}
+		if orig != ti.InYaml[1:] {
+			t.Errorf("original document was modified:\n%s", orig)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package replacement
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// SetOperation sets the source value to the target fields
+	SetOperation = "set"
+	// DeleteOperation deletes the target fields or the
+	// whole target resources if fieldrefs aren't set
+	DeleteOperation = "delete"
+	// CopyOperation copies the source field to the target fields.
+	// If the source objref isn't set the field of each target resource is used
+	CopyOperation = "copy"
+	// MoveOperation is the same as copy, but the source field is deleted
+	MoveOperation = "move"
+)
+
+func isValidOperation(o string) bool {
+	switch o {
+	case "", SetOperation, DeleteOperation, CopyOperation, MoveOperation:
+		return true
+	}
+	return false
+}
+
+// deleteTarget deletes the target fields of the matching resources or the
+// resources themselves. It returns the rest of items
+func deleteTarget(items []*yaml.RNode, t *Target) ([]*yaml.RNode, []*yaml.RNode, []Change, error) {
+	matching, err := t.ObjRef.Filter(items)
+	if err != nil {
+		return items, nil, nil, fmt.Errorf("error filtering by objref %v: %w", t.ObjRef, err)
+	}
+	changes := []Change{}
+	if len(t.FieldRefs) == 0 {
+		deleted := map[*yaml.RNode]bool{}
+		for _, node := range matching {
+			deleted[node] = true
+			changes = append(changes, newChange(node, ".", "", ""))
+		}
+		rest := []*yaml.RNode{}
+		for _, item := range items {
+			if !deleted[item] {
+				rest = append(rest, item)
+			}
+		}
+		return rest, matching, changes, nil
+	}
+
+	for _, node := range matching {
+		for _, fieldref := range t.FieldRefs {
+			ch, err := deleteField(node, fieldref)
+			if err != nil {
+				return items, nil, changes, fmt.Errorf("error deleting objref %v, fieldref %s, resource %s: %w",
+					t.ObjRef, fieldref, resourceID(node), err)
+			}
+			if ch != nil {
+				changes = append(changes, *ch)
+			}
+		}
+	}
+	return items, matching, changes, nil
+}
+
+// deleteField deletes the field of node. It returns nil
+// change if the field doesn't exist
+func deleteField(node *yaml.RNode, fieldRef string) (*Change, error) {
+	_, err := getFieldValue(node, fieldRef)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	oldValue := fieldValueString(node, fieldRef)
+	err = deleteFieldValue(node, fieldRef)
+	if err != nil {
+		return nil, err
+	}
+	ch := newChange(node, fieldRef, oldValue, "")
+	return &ch, nil
+}
+
+// copyTarget copies the source field to the target fields
+// and deletes the source field for move operation
+func (f *Function) copyTarget(items []*yaml.RNode, i int, r Replacement) ([]*yaml.RNode, []Change, error) {
+	t := r.Target
+	matching, err := t.ObjRef.Filter(items)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error filtering by objref %v: %w", t.ObjRef, err)
+	}
+	if len(matching) == 0 {
+		return matching, nil, nil
+	}
+
+	if r.Source.ObjRef != nil {
+		// the source is the same for all targets, so it's
+		// moved only after the value is set to all of them
+		from, err := r.Source.ObjRef.FindOne(items)
+		changes, err := f.copyField(from, err, matching, i, r)
+		return matching, changes, err
+	}
+
+	// each resource is the source for itself
+	changes := []Change{}
+	for _, node := range matching {
+		chs, err := f.copyField(node, nil, []*yaml.RNode{node}, i, r)
+		changes = append(changes, chs...)
+		if err != nil {
+			return nil, changes, err
+		}
+	}
+	return matching, changes, nil
+}
+
+// copyField copies the source field of from to the target fields of nodes
+// and deletes it after that for move operation. err is the error of the
+// lookup of from
+func (f *Function) copyField(from *yaml.RNode, err error, nodes []*yaml.RNode, i int, r Replacement) ([]Change, error) {
+	t := r.Target
+	var value interface{}
+	if err == nil {
+		value, err = getFieldValue(from, r.Source.FieldRef)
+	}
+	found := err == nil
+	value, skip, err := f.resolveValue(i, r, value, err)
+	if skip {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting source field %s: %w", r.Source.FieldRef, err)
+	}
+
+	changes := []Change{}
+	for _, node := range nodes {
+		for _, fieldref := range t.FieldRefs {
+			oldValue := fieldValueString(node, fieldref)
+			err := setFieldValueHandlingRegex(node, fieldref, value, t)
+			if err != nil {
+				return changes, fmt.Errorf("error setting value for objref %v, fieldref %s, value %s, resource %s: %w",
+					t.ObjRef, fieldref, targetValueString(node, fieldref, value), resourceID(node), err)
+			}
+			changes = append(changes, newChange(node, fieldref, oldValue, fieldValueString(node, fieldref)))
+		}
+	}
+
+	if t.Operation != MoveOperation || !found {
+		return changes, nil
+	}
+	ch, err := deleteField(from, r.Source.FieldRef)
+	if err != nil {
+		return changes, fmt.Errorf("error deleting source field %s of %s: %w",
+			r.Source.FieldRef, resourceID(from), err)
+	}
+	if ch != nil {
+		changes = append(changes, *ch)
+	}
+	return changes, nil
+}
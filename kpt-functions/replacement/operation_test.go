@@ -0,0 +1,304 @@
+package replacement
+
+import (
+	"bytes"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestOperations(t *testing.T) {
+	in := `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+spec:
+  image:
+    url: http://10.23.24.1/image.qcow2
+  online: true
+  bootMACAddress: 00:aa:bb:cc:dd:ee
+  args:
+  - --debug
+  - --v=2
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc
+  labels:
+    temporary: "true"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data:
+  mac: 00:11:22:33:44:55
+`
+	tc := []struct {
+		cfg         string
+		expectedOut string
+		expectedErr bool
+	}{
+		{
+			cfg: `
+replacements:
+- target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.image
+    - spec.args[=--debug]
+    - spec.missing
+    operation: delete
+- target:
+    objref:
+      kind: Secret
+      name: node1-bmc
+    operation: delete
+`,
+			expectedOut: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+spec:
+  online: true
+  bootMACAddress: 00:aa:bb:cc:dd:ee
+  args:
+  - --v=2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data:
+  mac: 00:11:22:33:44:55
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    fieldref: spec.bootMACAddress
+  target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.bootMAC
+    operation: move
+- source:
+    objref:
+      kind: ConfigMap
+      name: defaults
+    fieldref: data.mac
+  target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.defaultMAC
+    operation: copy
+`,
+			expectedOut: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+spec:
+  image:
+    url: http://10.23.24.1/image.qcow2
+  online: true
+  args:
+  - --debug
+  - --v=2
+  bootMAC: 00:aa:bb:cc:dd:ee
+  defaultMAC: 00:11:22:33:44:55
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc
+  labels:
+    temporary: "true"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data:
+  mac: 00:11:22:33:44:55
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: defaults
+    fieldref: data.mac
+  target:
+    objref:
+      kind: BareMetalHost
+    fieldrefs:
+    - spec.bootMACAddress
+    operation: move
+- target:
+    objref:
+      kind: Secret
+    operation: delete
+`,
+			expectedOut: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+spec:
+  image:
+    url: http://10.23.24.1/image.qcow2
+  online: true
+  bootMACAddress: 00:11:22:33:44:55
+  args:
+  - --debug
+  - --v=2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data: {}
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: defaults
+    fieldref: data.mac
+  target:
+    objref:
+      version: v1
+    fieldrefs:
+    - metadata.annotations.mac
+    operation: move
+`,
+			expectedOut: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node1
+spec:
+  image:
+    url: http://10.23.24.1/image.qcow2
+  online: true
+  bootMACAddress: 00:aa:bb:cc:dd:ee
+  args:
+  - --debug
+  - --v=2
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: node1-bmc
+  labels:
+    temporary: "true"
+  annotations:
+    mac: 00:11:22:33:44:55
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+  annotations:
+    mac: 00:11:22:33:44:55
+data: {}
+`,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    value: x
+  target:
+    objref:
+      kind: Secret
+    operation: delete
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+replacements:
+- target:
+    fieldrefs:
+    - spec.replicas
+    operation: delete
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+replacements:
+- source:
+    value: x
+  target:
+    objref:
+      kind: Secret
+    fieldrefs:
+    - data.a
+    operation: copy
+`,
+			expectedErr: true,
+		},
+	}
+
+	for i, ti := range tc {
+		for _, checkConflicts := range []bool{false, true} {
+			cfg := FunctionConfig{}
+			err := yaml.Unmarshal([]byte(ti.cfg), &cfg)
+			if err != nil {
+				t.Fatalf("can't unmarshal config %s: %v", ti.cfg, err)
+			}
+			cfg.CheckConflicts = checkConflicts
+			fn, err := NewFunction(&cfg)
+			if err != nil {
+				if !ti.expectedErr {
+					t.Errorf("%d: unexpected error: %v", i, err)
+				}
+				continue
+			}
+			if ti.expectedErr {
+				t.Errorf("%d: expected error", i)
+				continue
+			}
+			nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+			if err != nil {
+				t.Fatalf("can't read input: %v", err)
+			}
+			nodes, err = fn.Filter(nodes)
+			if err != nil {
+				t.Errorf("%d: unexpected error: %v", i, err)
+				continue
+			}
+			out := &bytes.Buffer{}
+			err = kio.ByteWriter{Writer: out}.Write(nodes)
+			if err != nil {
+				t.Fatalf("can't write output: %v", err)
+			}
+			if out.String() != ti.expectedOut {
+				t.Errorf("%d, checkConflicts %v: expected\n%s\ngot\n%s", i, checkConflicts, ti.expectedOut, out.String())
+			}
+		}
+	}
+}
+
+func TestExecDeleteResources(t *testing.T) {
+	f := Function{Config: &FunctionConfig{
+		Replacements: []Replacement{
+			{Target: &Target{ObjRef: &Selector{}, Operation: DeleteOperation}},
+		},
+	}}
+	err := f.Exec([]*yaml.RNode{yaml.MustParse("kind: ConfigMap\n")})
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
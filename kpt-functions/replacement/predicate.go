@@ -0,0 +1,88 @@
+package replacement
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// predicate is the condition of the sequence element selector
+// [key=value]. key can be the path in the element, e.g. metadata.name
+type predicate struct {
+	path  []string
+	value string
+}
+
+// parsePredicates parses the sequence element selector with several
+// conditions separated by , or with the nested key, e.g.
+// [name=ironic,protocol=TCP] or [metadata.name=data].
+// ok is false if p isn't such selector. Simple selectors like [name=ironic]
+// and [=value] are handled by kyaml
+func parsePredicates(p string) ([]predicate, bool, error) {
+	if len(p) < 2 || p[0] != '[' || p[len(p)-1] != ']' || !strings.Contains(p, "=") {
+		return nil, false, nil
+	}
+	conds := strings.Split(p[1:len(p)-1], ",")
+	preds := make([]predicate, 0, len(conds))
+	for _, c := range conds {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			if len(conds) == 1 {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("invalid condition %s in %s", c, p)
+		}
+		path, err := ParseFieldRef(kv[0])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid key %s in %s: %w", kv[0], p, err)
+		}
+		preds = append(preds, predicate{path: path, value: kv[1]})
+	}
+	if len(preds) == 1 && len(preds[0].path) == 1 {
+		return nil, false, nil
+	}
+	return preds, true, nil
+}
+
+// matchPredicates returns true if all conditions are true for the element
+func matchPredicates(elem *yaml.Node, preds []predicate) bool {
+	for _, pr := range preds {
+		v, err := yaml.NewRNode(elem).Pipe(yaml.Lookup(pr.path...))
+		if err != nil || v == nil || v.YNode().Kind != yaml.ScalarNode || v.YNode().Value != pr.value {
+			return false
+		}
+	}
+	return true
+}
+
+// findPredicates returns the index of the first element
+// of seq that matches preds or -1
+func findPredicates(seq *yaml.RNode, preds []predicate) int {
+	for j, elem := range seq.Content() {
+		if matchPredicates(elem, preds) {
+			return j
+		}
+	}
+	return -1
+}
+
+// newPredicatesElement returns the element with all key fields of preds set
+func newPredicatesElement(preds []predicate) (*yaml.Node, error) {
+	elem := yaml.NewRNode(&yaml.Node{Kind: yaml.MappingNode})
+	for _, pr := range preds {
+		parent := elem
+		if len(pr.path) > 1 {
+			var err error
+			parent, err = elem.Pipe(yaml.LookupCreate(yaml.MappingNode, pr.path[:len(pr.path)-1]...))
+			if err != nil {
+				return nil, err
+			}
+		}
+		err := parent.PipeE(yaml.SetField(pr.path[len(pr.path)-1], yaml.NewScalarRNode(pr.value)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return elem.YNode(), nil
+}
@@ -0,0 +1,79 @@
+package replacement
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// splitFieldRefPattern splits the fieldref with the substring pattern
+// appended as ...%PATTERN% to the path and the pattern. %% is
+// the escaped %. pattern is empty if the fieldref doesn't have it
+func splitFieldRefPattern(fieldRef string) (string, string, error) {
+	var path, pattern bytes.Buffer
+	cur := &path
+	inPattern := false
+	for i := 0; i < len(fieldRef); i++ {
+		if fieldRef[i] != '%' {
+			cur.WriteByte(fieldRef[i])
+			continue
+		}
+		if i+1 < len(fieldRef) && fieldRef[i+1] == '%' {
+			cur.WriteByte('%')
+			i++
+			continue
+		}
+		if !inPattern {
+			inPattern = true
+			cur = &pattern
+			continue
+		}
+		if i != len(fieldRef)-1 {
+			return "", "", fmt.Errorf("pattern must be at the end of fieldref %s, use %%%% for literal %%", fieldRef)
+		}
+		if pattern.Len() == 0 {
+			return "", "", fmt.Errorf("empty pattern in fieldref %s", fieldRef)
+		}
+		return path.String(), pattern.String(), nil
+	}
+	if inPattern {
+		return "", "", fmt.Errorf("unclosed pattern in fieldref %s, use %%%% for literal %%", fieldRef)
+	}
+	return path.String(), "", nil
+}
+
+// compileFieldRefPattern returns the path of fieldref and the
+// compiled pattern or nil if fieldref doesn't have it
+func compileFieldRefPattern(fieldRef string) (string, *regexp.Regexp, error) {
+	path, pattern, err := splitFieldRefPattern(fieldRef)
+	if err != nil || pattern == "" {
+		return path, nil, err
+	}
+	p, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid pattern in fieldref %s: %w", fieldRef, err)
+	}
+	return path, p, nil
+}
+
+// replacePattern replaces the matches of p in s with value. If p has
+// capture groups, value can refer to them as $1 or ${name}, otherwise
+// it's used literally
+func replacePattern(p *regexp.Regexp, s, value string, first bool) (string, error) {
+	loc := p.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return "", fmt.Errorf("wasn't able to match pattern %s with value %s", p, s)
+	}
+	expand := p.NumSubexp() > 0
+	if !first {
+		if expand {
+			return p.ReplaceAllString(s, value), nil
+		}
+		return p.ReplaceAllLiteralString(s, value), nil
+	}
+	repl := []byte(value)
+	if expand {
+		repl = p.ExpandString(nil, value, s, loc)
+	}
+	return s[:loc[0]] + string(repl) + s[loc[1]:], nil
+}
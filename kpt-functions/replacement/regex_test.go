@@ -0,0 +1,107 @@
+package replacement
+
+import (
+	"testing"
+)
+
+func TestSplitFieldRefPattern(t *testing.T) {
+	ts := []struct {
+		in              string
+		expectedPath    string
+		expectedPattern string
+		expectedErr     bool
+	}{
+		{in: "spec.image", expectedPath: "spec.image"},
+		{in: "spec.image%TAG%", expectedPath: "spec.image", expectedPattern: "TAG"},
+		{in: `metadata.annotations."a%%b"`, expectedPath: `metadata.annotations."a%b"`},
+		{in: `data.url%^(?P<scheme>\w+)://%%%`, expectedPath: "data.url", expectedPattern: `^(?P<scheme>\w+)://%`},
+		{in: "spec.image%TAG", expectedErr: true},
+		{in: "spec.image%TAG%.name", expectedErr: true},
+		{in: "spec.image%%%", expectedErr: true},
+		{in: "spec.image%%", expectedPath: "spec.image%"},
+	}
+
+	for _, ti := range ts {
+		path, pattern, err := splitFieldRefPattern(ti.in)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("unexpected error for %s: %v", ti.in, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("expected error for %s", ti.in)
+			continue
+		}
+		if path != ti.expectedPath || pattern != ti.expectedPattern {
+			t.Errorf("for %s expected %q and %q, got %q and %q",
+				ti.in, ti.expectedPath, ti.expectedPattern, path, pattern)
+		}
+	}
+}
+
+func TestReplacePattern(t *testing.T) {
+	ts := []struct {
+		fieldRef    string
+		in          string
+		value       string
+		first       bool
+		expectedOut string
+		expectedErr bool
+	}{
+		{fieldRef: "a%TAG%", in: "nginx:TAG", value: "1.0", expectedOut: "nginx:1.0"},
+		{fieldRef: "a%TAG%", in: "TAG-TAG", value: "1", expectedOut: "1-1"},
+		{fieldRef: "a%TAG%", in: "TAG-TAG", value: "1", first: true, expectedOut: "1-TAG"},
+		{fieldRef: "a%TAG%", in: "TAG", value: "pa$1", expectedOut: "pa$1"},
+		{fieldRef: "a%TAG%", in: "nginx", value: "1", expectedErr: true},
+		{
+			fieldRef:    `a%//(?P<host>[^:/]+):(?P<port>\d+)%`,
+			in:          "http://10.0.0.1:6385/v1",
+			value:       "//${host}:8080",
+			expectedOut: "http://10.0.0.1:8080/v1",
+		},
+		{
+			fieldRef:    `a%(?P<n>\d)%`,
+			in:          "1 2",
+			value:       "[${n}]",
+			first:       true,
+			expectedOut: "[1] 2",
+		},
+	}
+
+	for _, ti := range ts {
+		_, p, err := compileFieldRefPattern(ti.fieldRef)
+		if err != nil {
+			t.Errorf("can't compile %s: %v", ti.fieldRef, err)
+			continue
+		}
+		out, err := replacePattern(p, ti.in, ti.value, ti.first)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("unexpected error for %s: %v", ti.fieldRef, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("expected error for %s", ti.fieldRef)
+			continue
+		}
+		if out != ti.expectedOut {
+			t.Errorf("for %s expected %q, got %q", ti.fieldRef, ti.expectedOut, out)
+		}
+	}
+}
+
+func TestNewFunctionInvalidPattern(t *testing.T) {
+	_, err := NewFunction(&FunctionConfig{
+		Replacements: []Replacement{
+			{
+				Source: &Source{Value: "a"},
+				Target: &Target{ObjRef: &Selector{}, FieldRefs: []string{"spec.image%(%"}},
+			},
+		},
+	})
+	if err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
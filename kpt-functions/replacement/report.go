@@ -0,0 +1,198 @@
+package replacement
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ProvenanceAnnotation lists the fields changed by
+	// the replacements and their sources
+	ProvenanceAnnotation = "airshipit.org/replaced-fields"
+
+	reportAPIVersion = "airshipit.org/v1alpha1"
+	reportKind       = "ReplacementReport"
+
+	// redactedValue is shown instead of the values of Secrets and
+	// of the fields of the included documents in the report and errors
+	redactedValue = "<redacted>"
+)
+
+// ChangedResource identifies the resource changed by the replacement
+type ChangedResource struct {
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Name       string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// Change describes the field set by the replacement
+type Change struct {
+	// Replacement is the index of the replacement in config
+	Replacement int             `json:"replacement" yaml:"replacement"`
+	Source      string          `json:"source" yaml:"source"`
+	Target      ChangedResource `json:"target" yaml:"target"`
+	FieldRef    string          `json:"fieldref" yaml:"fieldref"`
+	OldValue    string          `json:"oldValue,omitempty" yaml:"oldValue,omitempty"`
+	NewValue    string          `json:"newValue,omitempty" yaml:"newValue,omitempty"`
+
+	node *yaml.RNode
+	// value is the new value that isn't redacted
+	value string
+}
+
+// newChange returns the change of the field. The values are
+// redacted if they are sensitive
+func newChange(node *yaml.RNode, fieldRef, oldValue, newValue string) Change {
+	ch := Change{FieldRef: fieldRef, OldValue: oldValue, NewValue: newValue, node: node, value: newValue}
+	if isSensitive(node, fieldRef) {
+		ch.OldValue = redact(oldValue)
+		ch.NewValue = redact(newValue)
+	}
+	meta, err := node.GetMeta()
+	if err == nil {
+		ch.Target = ChangedResource{
+			APIVersion: meta.APIVersion,
+			Kind:       meta.Kind,
+			Namespace:  meta.Namespace,
+			Name:       meta.Name,
+		}
+	}
+	return ch
+}
+
+// fieldValueString returns the value of the field as string
+// or empty string if the field doesn't exist
+func fieldValueString(node *yaml.RNode, fieldRef string) string {
+	// regex fieldrefs are read without the pattern
+	fieldRef, _, err := splitFieldRefPattern(fieldRef)
+	if err != nil {
+		return ""
+	}
+	v, err := getFieldValue(node, fieldRef)
+	if err != nil {
+		return ""
+	}
+	return valueString(v)
+}
+
+// isSensitive returns true if the values of the field mustn't be shown,
+// i.e. node is Secret or the field is in the included document, e.g.
+// data.password|base64
+func isSensitive(node *yaml.RNode, fieldRef string) bool {
+	meta, err := node.GetMeta()
+	if err == nil && meta.Kind == "Secret" {
+		return true
+	}
+	fieldRef, _, err = splitFieldRefPattern(fieldRef)
+	if err != nil {
+		return true
+	}
+	fieldRefs, err := ParseFieldRefs(fieldRef)
+	return err != nil || len(fieldRefs) > 1
+}
+
+// redact returns redactedValue instead of the non-empty value
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// targetValueString returns value as string to use in messages
+// about the field of node or redactedValue if it's sensitive
+func targetValueString(node *yaml.RNode, fieldRef string, value interface{}) string {
+	if isSensitive(node, fieldRef) {
+		return redactedValue
+	}
+	return valueString(value)
+}
+
+// sourceString describes the source for the provenance and report
+func sourceString(s *Source) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Value != "":
+		return "value"
+	case s.ValueYAML != nil:
+		return "valueYaml"
+	case s.ObjRef != nil:
+		return objRefString(s.ObjRef, s.FieldRef)
+	case s.FieldRef != "":
+		// copy and move use the field of the target resource itself
+		return s.FieldRef
+	case s.MultiRef != nil:
+		refs := []string{}
+		for _, r := range s.MultiRef.Refs {
+			refs = append(refs, objRefString(r.ObjRef, r.FieldRef))
+		}
+		return "multiref(" + strings.Join(refs, ", ") + ")"
+	}
+	return ""
+}
+
+func objRefString(r *SourceObjRef, fieldRef string) string {
+	if r == nil {
+		return fieldRef
+	}
+	if fieldRef == "" {
+		fieldRef = ".metadata.name"
+	}
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s:%s", r.Kind, name, fieldRef)
+}
+
+// annotate sets ProvenanceAnnotation of the changed resources
+// if provenance is enabled
+func (f *Function) annotate() error {
+	if !f.Config.Provenance {
+		return nil
+	}
+	nodes := []*yaml.RNode{}
+	lines := map[*yaml.RNode][]string{}
+	for _, ch := range f.Changes {
+		if _, ok := lines[ch.node]; !ok {
+			nodes = append(nodes, ch.node)
+		}
+		lines[ch.node] = append(lines[ch.node], ch.FieldRef+": "+ch.Source)
+	}
+	for _, node := range nodes {
+		err := node.PipeE(yaml.SetAnnotation(ProvenanceAnnotation, strings.Join(lines[node], "; ")))
+		if err != nil {
+			return fmt.Errorf("can't annotate %s: %w", resourceID(node), err)
+		}
+	}
+	return nil
+}
+
+// ReportNode returns the ReplacementReport resource with
+// the changes made by the last Exec
+func (f *Function) ReportNode() (*yaml.RNode, error) {
+	report := struct {
+		APIVersion string          `yaml:"apiVersion"`
+		Kind       string          `yaml:"kind"`
+		Metadata   yaml.ObjectMeta `yaml:"metadata"`
+		Changes    []Change        `yaml:"changes"`
+	}{
+		APIVersion: reportAPIVersion,
+		Kind:       reportKind,
+		Metadata: yaml.ObjectMeta{
+			Name: f.Config.Report,
+			// the report shouldn't be applied to the cluster
+			Annotations: map[string]string{"config.kubernetes.io/local-config": "true"},
+		},
+		Changes: f.Changes,
+	}
+	b, err := yaml.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Parse(string(b))
+}
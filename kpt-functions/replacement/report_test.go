@@ -0,0 +1,237 @@
+package replacement
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestProvenanceAndReport(t *testing.T) {
+	cfg := `
+provenance: true
+report: replacements
+replacements:
+- source:
+    objref:
+      kind: Catalogue
+      name: vars
+    fieldref: values.ntp
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.server
+    - data.image%TAG%
+- source:
+    value: debug
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.level
+`
+	in := `apiVersion: airshipit.org/v1alpha1
+kind: Catalogue
+metadata:
+  name: vars
+values:
+  ntp: 1.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: ns
+data:
+  server: 0.pool.ntp.org
+  image: ntp:TAG
+`
+	expectedOut := `apiVersion: airshipit.org/v1alpha1
+kind: Catalogue
+metadata:
+  name: vars
+values:
+  ntp: 1.pool.ntp.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: ns
+  annotations:
+    airshipit.org/replaced-fields: 'data.server: Catalogue vars:values.ntp; data.image%TAG%:
+      Catalogue vars:values.ntp; data.level: value'
+data:
+  server: 1.pool.ntp.org
+  image: ntp:1.pool.ntp.org
+  level: debug
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementReport
+metadata:
+  name: replacements
+  annotations:
+    config.kubernetes.io/local-config: "true"
+changes:
+- replacement: 0
+  source: Catalogue vars:values.ntp
+  target:
+    apiVersion: v1
+    kind: ConfigMap
+    namespace: ns
+    name: cm
+  fieldref: data.server
+  oldValue: 0.pool.ntp.org
+  newValue: 1.pool.ntp.org
+- replacement: 0
+  source: Catalogue vars:values.ntp
+  target:
+    apiVersion: v1
+    kind: ConfigMap
+    namespace: ns
+    name: cm
+  fieldref: data.image%TAG%
+  oldValue: ntp:TAG
+  newValue: ntp:1.pool.ntp.org
+- replacement: 1
+  source: value
+  target:
+    apiVersion: v1
+    kind: ConfigMap
+    namespace: ns
+    name: cm
+  fieldref: data.level
+  newValue: debug
+`
+
+	for _, checkConflicts := range []bool{false, true} {
+		config := FunctionConfig{}
+		err := yaml.Unmarshal([]byte(cfg), &config)
+		if err != nil {
+			t.Fatalf("can't unmarshal config: %v", err)
+		}
+		config.CheckConflicts = checkConflicts
+
+		fn, err := NewFunction(&config)
+		if err != nil {
+			t.Fatalf("can't create function: %v", err)
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Fatalf("can't read input: %v", err)
+		}
+		err = fn.Exec(nodes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		report, err := fn.ReportNode()
+		if err != nil {
+			t.Fatalf("can't build report: %v", err)
+		}
+		nodes = append(nodes, report)
+
+		out := &bytes.Buffer{}
+		err = kio.ByteWriter{Writer: out}.Write(nodes)
+		if err != nil {
+			t.Fatalf("can't write output: %v", err)
+		}
+		if out.String() != expectedOut {
+			t.Errorf("checkConflicts %v: expected\n%s\ngot\n%s", checkConflicts, expectedOut, out.String())
+		}
+	}
+}
+
+func TestReportRedaction(t *testing.T) {
+	in := `apiVersion: v1
+kind: Secret
+metadata:
+  name: bmc
+data:
+  password: b2xkUGFzcw==
+  user: YWRtaW4=
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  config: |
+    token: oldToken
+`
+	cfg := `
+report: replacements
+checkConflicts: true
+replacements:
+- source:
+    value: n3wP4ss
+  target:
+    objref:
+      kind: Secret
+    fieldrefs:
+    - data.password|base64
+- source:
+    value: root
+  target:
+    objref:
+      kind: Secret
+    fieldrefs:
+    - data.user
+- source:
+    value: newToken
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.config|token
+`
+	config := FunctionConfig{}
+	err := yaml.Unmarshal([]byte(cfg), &config)
+	if err != nil {
+		t.Fatalf("can't unmarshal config: %v", err)
+	}
+	fn, err := NewFunction(&config)
+	if err != nil {
+		t.Fatalf("can't create function: %v", err)
+	}
+	nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+	if err != nil {
+		t.Fatalf("can't read input: %v", err)
+	}
+	err = fn.Exec(nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report, err := fn.ReportNode()
+	if err != nil {
+		t.Fatalf("can't build report: %v", err)
+	}
+	s, err := report.String()
+	if err != nil {
+		t.Fatalf("can't write report: %v", err)
+	}
+	for _, v := range []string{"oldPass", "n3wP4ss", "b2xkUGFzcw==", "YWRtaW4=", "root", "oldToken", "newToken"} {
+		if strings.Contains(s, v) {
+			t.Errorf("report contains %s:\n%s", v, s)
+		}
+	}
+	if strings.Count(s, "newValue: <redacted>") != 3 {
+		t.Errorf("expected 3 redacted values in report:\n%s", s)
+	}
+
+	// the conflicts are still found, but the values aren't shown
+	config.Replacements = append(config.Replacements, Replacement{
+		Source: &Source{Value: "other"},
+		Target: &Target{ObjRef: &Selector{Gvk: Gvk{Kind: "Secret"}}, FieldRefs: []string{"data.password|base64"}},
+	})
+	nodes, err = (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+	if err != nil {
+		t.Fatalf("can't read input: %v", err)
+	}
+	err = fn.Exec(nodes)
+	if err == nil || !strings.Contains(err.Error(), `is set to "<redacted>" and "<redacted>"`) {
+		t.Errorf("expected redacted conflict error, got %v", err)
+	}
+}
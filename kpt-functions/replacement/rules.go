@@ -0,0 +1,46 @@
+package replacement
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// loadRules returns the items without the documents selected
+// by Rules and the replacements of these documents. Function
+// configs, including the config of this function if it's in
+// the items, are never used as rules
+func (f *Function) loadRules(items []*yaml.RNode) ([]*yaml.RNode, []Replacement, error) {
+	if f.Config.Rules == nil {
+		return items, nil, nil
+	}
+	matching, err := f.Config.Rules.Filter(items)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error filtering rules by %v: %w", f.Config.Rules, err)
+	}
+	isRule := map[*yaml.RNode]bool{}
+	for _, m := range matching {
+		isRule[m] = runtimeutil.GetFunctionSpec(m) == nil
+	}
+
+	rest := []*yaml.RNode{}
+	rs := []Replacement{}
+	for _, item := range items {
+		if !isRule[item] {
+			rest = append(rest, item)
+			continue
+		}
+		cfg := FunctionConfig{}
+		err := item.YNode().Decode(&cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't read rules %s: %w", resourceID(item), err)
+		}
+		err = validateReplacements(cfg.Replacements)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid rules %s: %w", resourceID(item), err)
+		}
+		rs = append(rs, cfg.Replacements...)
+	}
+	return rest, rs, nil
+}
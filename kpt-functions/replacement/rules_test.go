@@ -0,0 +1,264 @@
+package replacement
+
+import (
+	"bytes"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestRules(t *testing.T) {
+	in := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: old
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: second
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.a
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.c
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: third
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.c
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.d
+`
+	tc := []struct {
+		cfg         string
+		expectedOut string
+		expectedErr bool
+	}{
+		{
+			cfg: `
+rules:
+  kind: ReplacementTransformer
+replacements:
+- source:
+    value: new
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: new
+  c: new
+  d: new
+`,
+		},
+		{
+			cfg: `
+rules:
+  kind: ReplacementTransformer
+  name: third
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+rules:
+  kind: ReplacementTransformer
+  name: second
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: old
+  c: old
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: third
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.c
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.d
+`,
+		},
+	}
+
+	for i, ti := range tc {
+		cfg := FunctionConfig{}
+		err := yaml.Unmarshal([]byte(ti.cfg), &cfg)
+		if err != nil {
+			t.Fatalf("can't unmarshal config %s: %v", ti.cfg, err)
+		}
+		fn, err := NewFunction(&cfg)
+		if err != nil {
+			t.Fatalf("can't create function: %v", err)
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Fatalf("can't read input: %v", err)
+		}
+		nodes, err = fn.Filter(nodes)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("%d: expected error", i)
+			continue
+		}
+		out := &bytes.Buffer{}
+		err = kio.ByteWriter{Writer: out}.Write(nodes)
+		if err != nil {
+			t.Fatalf("can't write output: %v", err)
+		}
+		if out.String() != ti.expectedOut {
+			t.Errorf("%d: expected\n%s\ngot\n%s", i, ti.expectedOut, out.String())
+		}
+	}
+}
+
+func TestRulesSkipFunctionConfig(t *testing.T) {
+	in := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: old
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: replacements
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: quay.io/aodinokov/replacement-default:v0.0.2
+rules:
+  kind: ReplacementTransformer
+replacements:
+- source:
+    value: new
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: ephemeral
+  labels:
+    airshipit.org/replacements: ephemeral
+replacements:
+- source:
+    objref:
+      kind: ConfigMap
+      name: cm
+    fieldref: data.a
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.b
+`
+	cfg := FunctionConfig{
+		Rules: &Selector{
+			Gvk:           Gvk{Kind: "ReplacementTransformer"},
+			LabelSelector: "airshipit.org/replacements=ephemeral",
+		},
+		Replacements: []Replacement{
+			{
+				Source: &Source{Value: "new"},
+				Target: &Target{ObjRef: &Selector{Gvk: Gvk{Kind: "ConfigMap"}}, FieldRefs: []string{"data.a"}},
+			},
+		},
+	}
+	expectedOut := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: new
+  b: new
+---
+apiVersion: airshipit.org/v1alpha1
+kind: ReplacementTransformer
+metadata:
+  name: replacements
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: quay.io/aodinokov/replacement-default:v0.0.2
+rules:
+  kind: ReplacementTransformer
+replacements:
+- source:
+    value: new
+  target:
+    objref:
+      kind: ConfigMap
+    fieldrefs:
+    - data.a
+`
+
+	for _, rules := range []*Selector{cfg.Rules, {Gvk: Gvk{Kind: "ReplacementTransformer"}}} {
+		cfg.Rules = rules
+		fn, err := NewFunction(&cfg)
+		if err != nil {
+			t.Fatalf("can't create function: %v", err)
+		}
+		nodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(in)}).Read()
+		if err != nil {
+			t.Fatalf("can't read input: %v", err)
+		}
+		nodes, err = fn.Filter(nodes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out := &bytes.Buffer{}
+		err = kio.ByteWriter{Writer: out}.Write(nodes)
+		if err != nil {
+			t.Fatalf("can't write output: %v", err)
+		}
+		if out.String() != expectedOut {
+			t.Errorf("rules %v: expected\n%s\ngot\n%s", rules, expectedOut, out.String())
+		}
+	}
+}
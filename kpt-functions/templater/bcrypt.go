@@ -0,0 +1,72 @@
+package templater
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/blowfish"
+)
+
+const bcryptSaltSize = 16
+
+// bcryptEncoding is base64 with the alphabet of bcrypt and without padding
+var bcryptEncoding = base64.NewEncoding("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789").
+	WithPadding(base64.NoPadding)
+
+// bcryptMagic is "OrpheanBeholderScryDoubt" that is encrypted by bcrypt
+var bcryptMagic = []byte("OrpheanBeholderScryDoubt")
+
+// bcryptWithSalt returns the bcrypt hash of password in the same format as
+// golang.org/x/crypto/bcrypt, but with the given salt instead of random one
+func bcryptWithSalt(password, salt []byte, cost int) (string, error) {
+	if len(salt) != bcryptSaltSize {
+		return "", fmt.Errorf("bcrypt salt must be %d bytes", bcryptSaltSize)
+	}
+	// the trailing zero is used in the key as in C implementations
+	key := append(append([]byte{}, password...), 0)
+	c, err := blowfish.NewSaltedCipher(key, salt)
+	if err != nil {
+		return "", err
+	}
+	for i := uint64(0); i < 1<<uint(cost); i++ {
+		blowfish.ExpandKey(key, c)
+		blowfish.ExpandKey(salt, c)
+	}
+
+	data := append([]byte{}, bcryptMagic...)
+	for i := 0; i < len(data); i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(data[i:i+8], data[i:i+8])
+		}
+	}
+	// only 23 of 24 bytes are encoded as in C implementations
+	return fmt.Sprintf("$2a$%02d$%s%s", cost,
+		bcryptEncoding.EncodeToString(salt), bcryptEncoding.EncodeToString(data[:23])), nil
+}
+
+// bcryptHash returns the bcrypt hash of password with the seeded salt
+func (s *seeded) bcryptHash(id, password string) (string, error) {
+	if err := s.check(); err != nil {
+		return "", err
+	}
+	salt := make([]byte, bcryptSaltSize)
+	if _, err := io.ReadFull(s.reader(id), salt); err != nil {
+		return "", err
+	}
+	return bcryptWithSalt([]byte(password), salt, bcrypt.DefaultCost)
+}
+
+// htpasswd returns the htpasswd line for user with the seeded bcrypt hash of password
+func (s *seeded) htpasswd(id, user, password string) (string, error) {
+	if strings.Contains(user, ":") {
+		return "", fmt.Errorf("user can't contain ':'")
+	}
+	h, err := s.bcryptHash(id, password)
+	if err != nil {
+		return "", err
+	}
+	return user + ":" + h, nil
+}
@@ -0,0 +1,47 @@
+package templater
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// entryValues returns entry merged over values.
+// values aren't modified
+func entryValues(values, entry map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	mergeValues(out, values)
+	mergeValues(out, entry)
+	return out
+}
+
+// entryPath returns the file for the documents generated
+// for entry: <name>.yaml if the entry has name, otherwise
+// foreach_<index>.yaml
+func entryPath(i int, entry map[string]interface{}) string {
+	if name, ok := entry["name"].(string); ok && name != "" {
+		return name + ".yaml"
+	}
+	return fmt.Sprintf("foreach_%d.yaml", i)
+}
+
+// setEntryPath sets path annotation, so the documents are written
+// to the separate file. The documents with path set by template
+// aren't changed.
+func setEntryPath(nodes []*yaml.RNode, path string) error {
+	for _, node := range nodes {
+		p, err := node.Pipe(yaml.GetAnnotation(kioutil.PathAnnotation))
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			continue
+		}
+		err = node.PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, path))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package funcs contains the template functions that are
+// used by templater and replacement functions
+package funcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"golang.org/x/crypto/bcrypt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// FuncMap returns sprig functions with the additional ones
+func FuncMap() template.FuncMap {
+	funcMap := sprig.TxtFuncMap()
+
+	extra := template.FuncMap{
+		"toYaml":       ToYaml,
+		"fromYaml":     fromYaml,
+		"fromJson":     fromJSON,
+		"toJson":       toJSON,
+		"cidrHost":     cidrHost,
+		"cidrSubnet":   cidrSubnet,
+		"ipAdd":        ipAdd,
+		"macAdd":       macAdd,
+		"macNormalize": macNormalize,
+		"required":     required,
+		"htpasswd":     htpasswd,
+		"bcrypt":       bcryptHash,
+		// tpl uses funcMap when it's called, so the functions
+		// added to funcMap after FuncMap call are available too
+		"tpl": func(text string, data interface{}) (string, error) {
+			return tpl(funcMap, text, data)
+		},
+	}
+	for k, v := range extra {
+		funcMap[k] = v
+	}
+	return funcMap
+}
+
+// ToYaml renders v as yaml. Errors are swallowed inside of a template
+func ToYaml(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func fromYaml(s string) (interface{}, error) {
+	var v interface{}
+	err := yaml.Unmarshal([]byte(s), &v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func fromJSON(s string) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal([]byte(s), &v)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func tpl(funcMap template.FuncMap, text string, data interface{}) (string, error) {
+	t, err := template.New("tpl").Funcs(funcMap).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = t.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// required returns error with msg if v is nil or empty string
+func required(msg string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// htpasswd returns the htpasswd line for user with bcrypt hash of password.
+// The salt is random, so the line is different on every run
+func htpasswd(user, password string) (string, error) {
+	if strings.Contains(user, ":") {
+		return "", fmt.Errorf("user can't contain ':'")
+	}
+	h, err := bcryptHash(password)
+	if err != nil {
+		return "", err
+	}
+	return user + ":" + h, nil
+}
+
+// bcryptHash returns bcrypt hash of password with random salt. The hash
+// is different on every run, but any of them matches the password
+func bcryptHash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// addToIP adds n to ip. The result must fit to the same address family
+func addToIP(ip net.IP, n *big.Int) (net.IP, error) {
+	size := net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, size = ip4, net.IPv4len
+	}
+	v := new(big.Int).SetBytes(ip)
+	v.Add(v, n)
+	if v.Sign() < 0 || v.BitLen() > size*8 {
+		return nil, fmt.Errorf("%s + %s is out of address range", ip, n)
+	}
+	b := v.Bytes()
+	out := make(net.IP, size)
+	copy(out[size-len(b):], b)
+	return out, nil
+}
+
+// ipAdd returns ip + n
+func ipAdd(ip string, n int) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid ip %s", ip)
+	}
+	out, err := addToIP(parsed, big.NewInt(int64(n)))
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// cidrHost returns the address of the host with number hostnum in
+// the network cidr. Negative numbers are counted from the end of the range
+func cidrHost(cidr string, hostnum int) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+
+	n := big.NewInt(int64(hostnum))
+	if hostnum < 0 {
+		n.Add(n, size)
+	}
+	if n.Sign() < 0 || n.Cmp(size) >= 0 {
+		return "", fmt.Errorf("host number %d doesn't fit to %s", hostnum, cidr)
+	}
+	ip, err := addToIP(network.IP, n)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// cidrSubnet returns the subnet number netnum of the network
+// cidr with the prefix extended by newbits
+func cidrSubnet(cidr string, newbits, netnum int) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	if newbits < 0 || ones+newbits > bits {
+		return "", fmt.Errorf("can't extend prefix of %s by %d bits", cidr, newbits)
+	}
+	if netnum < 0 || big.NewInt(int64(netnum)).BitLen() > newbits {
+		return "", fmt.Errorf("network number %d doesn't fit to %d bits", netnum, newbits)
+	}
+
+	n := new(big.Int).Lsh(big.NewInt(int64(netnum)), uint(bits-ones-newbits))
+	ip, err := addToIP(network.IP, n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d", ip, ones+newbits), nil
+}
+
+func parseMAC(mac string) (net.HardwareAddr, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("only 48 bits mac addresses are supported: %s", mac)
+	}
+	return hw, nil
+}
+
+// macNormalize returns mac in lower case separated by colons
+func macNormalize(mac string) (string, error) {
+	hw, err := parseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	return hw.String(), nil
+}
+
+// macAdd returns mac + n
+func macAdd(mac string, n int) (string, error) {
+	hw, err := parseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	v := new(big.Int).SetBytes(hw)
+	v.Add(v, big.NewInt(int64(n)))
+	if v.Sign() < 0 || v.BitLen() > 48 {
+		return "", fmt.Errorf("%s + %d is out of mac address range", mac, n)
+	}
+	b := v.Bytes()
+	out := make(net.HardwareAddr, 6)
+	copy(out[6-len(b):], b)
+	return out.String(), nil
+}
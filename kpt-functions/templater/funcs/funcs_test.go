@@ -0,0 +1,79 @@
+package funcs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestFuncs(t *testing.T) {
+	ts := []struct {
+		tmpl        string
+		data        interface{}
+		expectedOut string
+		expectedErr bool
+	}{
+		{tmpl: `{{ (fromYaml "a:\n  b: [1, 2]").a.b | toJson }}`, expectedOut: `[1,2]`},
+		{tmpl: `{{ (fromJson "{\"a\": \"b\"}").a }}`, expectedOut: `b`},
+		{tmpl: `{{ fromJson "{" }}`, expectedErr: true},
+		{tmpl: `{{ toJson .}}`, data: map[string]interface{}{"a": []int{1}}, expectedOut: `{"a":[1]}`},
+		{tmpl: `{{ cidrHost "10.0.0.0/24" 10 }}`, expectedOut: `10.0.0.10`},
+		{tmpl: `{{ cidrHost "10.0.0.0/24" -2 }}`, expectedOut: `10.0.0.254`},
+		{tmpl: `{{ cidrHost "fd00::/64" 257 }}`, expectedOut: `fd00::101`},
+		{tmpl: `{{ cidrHost "10.0.0.0/24" 256 }}`, expectedErr: true},
+		{tmpl: `{{ cidrSubnet "10.0.0.0/16" 8 2 }}`, expectedOut: `10.0.2.0/24`},
+		{tmpl: `{{ cidrSubnet "10.0.0.0/16" 8 256 }}`, expectedErr: true},
+		{tmpl: `{{ ipAdd "10.0.0.255" 1 }}`, expectedOut: `10.0.1.0`},
+		{tmpl: `{{ ipAdd "10.0.1.1" -2 }}`, expectedOut: `10.0.0.255`},
+		{tmpl: `{{ ipAdd "0.0.0.0" -1 }}`, expectedErr: true},
+		{tmpl: `{{ ipAdd "not ip" 1 }}`, expectedErr: true},
+		{tmpl: `{{ macAdd "00:AA:bb:cc:dd:ff" 1 }}`, expectedOut: `00:aa:bb:cc:de:00`},
+		{tmpl: `{{ macNormalize "00-AA-BB-CC-DD-EE" }}`, expectedOut: `00:aa:bb:cc:dd:ee`},
+		{tmpl: `{{ macAdd "ff:ff:ff:ff:ff:ff" 1 }}`, expectedErr: true},
+		{tmpl: `{{ required "name is required" .name }}`, data: map[string]interface{}{"name": "a"}, expectedOut: `a`},
+		{tmpl: `{{ required "name is required" .name }}`, data: map[string]interface{}{}, expectedErr: true},
+		{tmpl: `{{ required "name is required" .name }}`, data: map[string]interface{}{"name": ""}, expectedErr: true},
+		{tmpl: `{{ tpl "{{ .a | upper }}" . }}`, data: map[string]interface{}{"a": "b"}, expectedOut: `B`},
+		{tmpl: `{{ htpasswd "a:b" "c" }}`, expectedErr: true},
+	}
+
+	for _, ti := range ts {
+		tmpl, err := template.New("tmpl").Funcs(FuncMap()).Parse(ti.tmpl)
+		if err != nil {
+			t.Errorf("can't parse %s: %v", ti.tmpl, err)
+			continue
+		}
+		var out bytes.Buffer
+		err = tmpl.Execute(&out, ti.data)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("unexpected error for %s: %v", ti.tmpl, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("expected error for %s", ti.tmpl)
+			continue
+		}
+		if out.String() != ti.expectedOut {
+			t.Errorf("for %s expected %q, got %q", ti.tmpl, ti.expectedOut, out.String())
+		}
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	s, err := htpasswd("ironic", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] != "ironic" {
+		t.Fatalf("unexpected htpasswd line %s", s)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(parts[1]), []byte("secret")); err != nil {
+		t.Errorf("hash doesn't match password: %v", err)
+	}
+}
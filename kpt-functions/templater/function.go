@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"text/template"
 
-	"github.com/Masterminds/sprig"
+	"github.com/aodinokov/noctl-airship-poc/kpt-functions/templater/funcs"
 
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -14,11 +14,40 @@ import (
 type FunctionConfig struct {
 	// Values contains map with object parameters to render
 	Values map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+	// ValuesFrom lists the sources of values. The values are merged
+	// in the listed order and Values override them
+	ValuesFrom []ValuesFrom `json:"valuesFrom,omitempty" yaml:"valuesFrom,omitempty"`
 	// Template field is used to specify actual go-template which is going
 	// to be used to render the object defined in Spec field
 	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Templates contains named templates that can be used in Template
+	// with template action or include function
+	Templates map[string]string `json:"templates,omitempty" yaml:"templates,omitempty"`
+	// Libraries refer to ConfigMaps with templates, e.g. with define actions
+	Libraries []LibraryRef `json:"libraries,omitempty" yaml:"libraries,omitempty"`
+	// ForEach contains the list of values. If it's set, the template
+	// is rendered for each entry merged over Values and the generated
+	// documents are annotated to be stored to the separate files
+	ForEach []map[string]interface{} `json:"forEach,omitempty" yaml:"forEach,omitempty"`
+	// PathPattern sets config.kubernetes.io/path annotation of the
+	// generated documents, e.g. generated/{{kind}}_{{name}}.yaml
+	PathPattern string `json:"pathPattern,omitempty" yaml:"pathPattern,omitempty"`
 	// Remove all documents before adding the generated one
 	CleanPipeline bool `json:"cleanPipeline,omitempty" yaml:"cleanPipeline,omitempty"`
+	// Seed refers to the Secret in the input items with the seed
+	// for the seeded functions, e.g. seededRandAscii
+	Seed *SeedRef `json:"seed,omitempty" yaml:"seed,omitempty"`
+	// GenerateOnce keeps the input items instead of the generated
+	// documents with the same apiVersion, kind, namespace and name
+	GenerateOnce bool `json:"generateOnce,omitempty" yaml:"generateOnce,omitempty"`
+	// OutputMode defines what to do with the input items that have
+	// the same apiVersion, kind, namespace and name as the generated
+	// documents: append (default), replace, merge or fail
+	OutputMode string `json:"outputMode,omitempty" yaml:"outputMode,omitempty"`
+	// Strict makes missing keys and toYaml errors fail the template
+	// and validates the generated documents against the OpenAPI
+	// schemas of kubernetes types and CRDs from the input items
+	Strict bool `json:"strict,omitempty" yaml:"strict,omitempty"`
 }
 
 type Function struct {
@@ -26,47 +55,143 @@ type Function struct {
 }
 
 func NewFunction(cfg *FunctionConfig) (*Function, error) {
+	if !isValidOutputMode(cfg.OutputMode) {
+		return nil, fmt.Errorf("unknown output mode %s", cfg.OutputMode)
+	}
+	// generateOnce always keeps the input items
+	if cfg.GenerateOnce && cfg.OutputMode != "" && cfg.OutputMode != AppendOutputMode {
+		return nil, fmt.Errorf("output mode %s can't be used with generateOnce", cfg.OutputMode)
+	}
 	fn := Function{Config: cfg}
 	return &fn, nil
 }
 
 func (f *Function) Exec(items []*yaml.RNode) ([]*yaml.RNode, error) {
-	var out bytes.Buffer
+	var err error
 
-	funcMap := sprig.TxtFuncMap()
-	funcMap["toYaml"] = toYaml
-	tmpl, err := template.New("tmpl").Funcs(funcMap).Parse(f.Config.Template)
+	funcMap := funcs.FuncMap()
+	if f.Config.Strict {
+		funcMap["toYaml"] = toYamlStrict
+	}
+
+	var s *seeded
+	if f.Config.Seed != nil {
+		s, err = newSeeded(items, f.Config.Seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range s.funcMap() {
+		funcMap[k] = v
+	}
+
+	itf, err := newItemsFuncs(items)
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range itf.funcMap() {
+		funcMap[k] = v
+	}
 
-	err = tmpl.Execute(&out, f.Config.Values)
+	tmpl, err := f.template(items, funcMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Config.Strict {
+		tmpl.Option("missingkey=error")
+		// map the validation errors to the template lines
+		if tmpl.Tree != nil {
+			markLines(tmpl.Tree.Root, f.Config.Template)
+		}
+	}
+
+	values, err := f.values(items)
+	if err != nil {
+		return nil, err
+	}
+
+	var generated []*yaml.RNode
+	if f.Config.ForEach == nil {
+		generated, err = f.render(items, tmpl, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, entry := range f.Config.ForEach {
+		nodes, err := f.render(items, tmpl, entryValues(values, entry))
+		if err != nil {
+			return nil, fmt.Errorf("forEach[%d]: %w", i, err)
+		}
+		err = setEntryPath(nodes, entryPath(i, entry))
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, nodes...)
+	}
+
+	var existing []*yaml.RNode
+	if !f.Config.CleanPipeline {
+		existing = items
+	}
+	err = setOutputAnnotations(existing, generated, f.Config.PathPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if !f.Config.GenerateOnce {
+		if f.Config.CleanPipeline {
+			return generated, nil
+		}
+		return upsert(items, generated, f.Config.OutputMode)
+	}
+
+	nodes, fresh, err := generateOnce(items, generated)
+	if err != nil {
+		return nil, err
+	}
+	if f.Config.CleanPipeline {
+		return nodes, nil
+	}
+	return append(items, fresh...), nil
+}
+
+// render executes tmpl with values and converts the result to nodes
+func (f *Function) render(items []*yaml.RNode, tmpl *template.Template, values interface{}) ([]*yaml.RNode, error) {
+	var out bytes.Buffer
+	err := tmpl.Execute(&out, values)
 	if err != nil {
 		return nil, fmt.Errorf("template exec returned error: %v", err)
 	}
 
+	rendered := out.String()
+	if f.Config.Strict {
+		var lines []int
+		rendered, lines = splitLines(rendered)
+		err = validateOutput(items, rendered, lines)
+		if err != nil {
+			return nil, fmt.Errorf("generated document is invalid: %w", err)
+		}
+	}
+
 	// Convert string to Rnodes
 	pb := kio.PackageBuffer{}
 	p := kio.Pipeline{
-		Inputs:  []kio.Reader{&kio.ByteReader{Reader: bytes.NewBufferString(out.String())}},
+		Inputs:  []kio.Reader{&kio.ByteReader{Reader: bytes.NewBufferString(rendered)}},
 		Outputs: []kio.Writer{&pb},
 	}
 	err = p.Execute()
 	if err != nil {
 		return nil, err
 	}
-	if f.Config.CleanPipeline {
-		return pb.Nodes, nil
-	}
-	return append(items, pb.Nodes...), nil
+	return pb.Nodes, nil
 }
 
-// Render input yaml as output yaml
-func toYaml(v interface{}) string {
+// toYamlStrict is toYaml that returns errors
+func toYamlStrict(v interface{}) (string, error) {
 	data, err := yaml.Marshal(v)
 	if err != nil {
-		// Swallow errors inside of a template.
-		return ""
+		return "", err
 	}
-	return string(data)
+	return string(data), nil
 }
@@ -150,6 +150,569 @@ template: |
 kind: ConfigMap
 metadata:
   name: map2
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+seed:
+  name: seed
+template: |
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: pw
+  stringData:
+    password: {{ seededRandAlphaNum "Secret/pw/password" 16 }}
+`,
+			in: `apiVersion: v1
+kind: Secret
+metadata:
+  name: seed
+data:
+  seed: czNjcjN0
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: seed
+data:
+  seed: czNjcjN0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: pw
+stringData:
+  password: 4YcFKOr0X9mzRCfp
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+template: |
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: pw
+  stringData:
+    password: {{ seededRandAlphaNum "Secret/pw/password" 16 }}
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+generateOnce: true
+template: |
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: pw
+  stringData:
+    password: {{ randAlphaNum 16 }}
+  ---
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: other
+  stringData:
+    password: new
+`,
+			in: `apiVersion: v1
+kind: Secret
+metadata:
+  name: pw
+stringData:
+  password: old
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: pw
+stringData:
+  password: old
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: other
+stringData:
+  password: new
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+cleanPipeline: true
+template: |
+  {{- $cfg := lookup "v1" "ConfigMap" "" "cfg" }}
+  {{- range getByKind "BareMetalHost" }}
+  ---
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ getField . "metadata.name" }}-network
+  data:
+    mac: {{ .spec.bootMACAddress }}
+    domain: {{ getField $cfg "data.domain" }}
+  {{- end }}
+`,
+			in: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+spec:
+  bootMACAddress: 00:aa:bb:cc:dd
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  domain: example.com
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-2
+spec:
+  bootMACAddress: 00:aa:bb:cc:ee
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: node-1-network
+data:
+  mac: 00:aa:bb:cc:dd
+  domain: example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: node-2-network
+data:
+  mac: 00:aa:bb:cc:ee
+  domain: example.com
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+cleanPipeline: true
+valuesFrom:
+- configMapRef:
+    name: defaults
+    key: values.yaml
+- secretRef:
+    name: creds
+- configMapRef:
+    name: missing
+  optional: true
+values:
+  bmc:
+    port: 8443
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: out
+  data:
+    address: {{ .bmc.address }}:{{ .bmc.port }}
+    user: {{ .user }}
+    password: {{ .password }}
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: defaults
+data:
+  values.yaml: |
+    bmc:
+      address: 10.0.0.1
+      port: 443
+    user: default
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  user: YWRtaW4=
+stringData:
+  password: secret
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: out
+data:
+  address: 10.0.0.1:8443
+  user: admin
+  password: secret
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+valuesFrom:
+- configMapRef:
+    name: missing
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: out
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+outputMode: replace
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map1
+  data:
+    b: new
+  ---
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map2
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: old
+  b: old
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  b: new
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map2
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+outputMode: merge
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map1
+  data:
+    b: new
+  ---
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map2
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: old
+  b: old
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: old
+  b: new
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map2
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+outputMode: merge
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map1
+  data:
+    b: new
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map0
+  annotations:
+    config.kubernetes.io/path: 'maps.yaml'
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+  annotations:
+    config.kubernetes.io/path: 'maps.yaml'
+data:
+  a: old
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map0
+  annotations:
+    config.kubernetes.io/path: 'maps.yaml'
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+  annotations:
+    config.kubernetes.io/path: 'maps.yaml'
+data:
+  a: old
+  b: new
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+outputMode: fail
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map1
+  data:
+    b: new
+  ---
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: map2
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: old
+  b: old
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+cleanPipeline: true
+values:
+  hosts:
+  - name: node-1
+    mac: 00:aa:bb:cc:dd
+libraries:
+- name: network-lib
+templates:
+  host: |
+    apiVersion: metal3.io/v1alpha1
+    kind: BareMetalHost
+    metadata:
+      name: {{ .name }}
+    spec:
+      {{- include "network" . | nindent 2 }}
+template: |
+  {{- range .hosts }}
+  ---
+  {{ template "host" . }}
+  {{- end }}
+`,
+			in: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: network-lib
+data:
+  network.tpl: |
+    {{- define "network" -}}
+    bootMACAddress: {{ .mac }}
+    {{- end -}}
+`,
+			expectedOut: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+spec:
+  bootMACAddress: 00:aa:bb:cc:dd
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+template: |
+  {{ include "missing" . }}
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+strict: true
+values:
+  name: map1
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .nmae }}
+`,
+			expectedErr: true,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+strict: true
+values:
+  name: map1
+  data:
+    a: b
+template: |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: {{ .name }}
+  data:
+    {{- toYaml .data | nindent 2 }}
+`,
+			expectedOut: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: b
+`,
+		},
+		{
+			cfg: `
+apiVersion: airshipit.org/v1alpha1
+kind: Templater
+metadata:
+  name: notImportantHere
+values:
+  namespace: metal3
+  bmc:
+    port: 443
+forEach:
+- name: node-1
+  bmc:
+    address: 10.0.0.1
+- bmc:
+    address: 10.0.0.2
+    port: 8443
+template: |
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: {{ .name | default "unnamed" }}-bmc
+    namespace: {{ .namespace }}
+  stringData:
+    address: {{ .bmc.address }}:{{ .bmc.port }}
+  ---
+  apiVersion: metal3.io/v1alpha1
+  kind: BareMetalHost
+  metadata:
+    name: {{ .name | default "unnamed" }}
+    namespace: {{ .namespace }}
+`,
+			expectedOut: `apiVersion: v1
+kind: Secret
+metadata:
+  name: node-1-bmc
+  namespace: metal3
+  annotations:
+    config.kubernetes.io/path: 'node-1.yaml'
+stringData:
+  address: 10.0.0.1:443
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+  namespace: metal3
+  annotations:
+    config.kubernetes.io/path: 'node-1.yaml'
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: unnamed-bmc
+  namespace: metal3
+  annotations:
+    config.kubernetes.io/path: 'foreach_1.yaml'
+stringData:
+  address: 10.0.0.2:8443
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: unnamed
+  namespace: metal3
+  annotations:
+    config.kubernetes.io/path: 'foreach_1.yaml'
 `,
 		},
 	}
@@ -174,6 +737,10 @@ metadata:
 			t.Errorf("exec %d returned unexpected error %v for %s", i, err, ti.cfg)
 			continue
 		}
+		if err == nil && ti.expectedErr {
+			t.Errorf("exec %d expected error for %s", i, ti.cfg)
+			continue
+		}
 		out := &bytes.Buffer{}
 		err = kio.ByteWriter{Writer: out}.Write(nodes)
 		if err != nil {
@@ -186,3 +753,14 @@ metadata:
 	}
 
 }
+
+func TestNewFunctionOutputMode(t *testing.T) {
+	_, err := NewFunction(&FunctionConfig{GenerateOnce: true, OutputMode: ReplaceOutputMode})
+	if err == nil {
+		t.Errorf("expected error for generateOnce with replace output mode")
+	}
+	_, err = NewFunction(&FunctionConfig{GenerateOnce: true, OutputMode: AppendOutputMode})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
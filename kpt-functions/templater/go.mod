@@ -6,8 +6,10 @@ require (
 	github.com/Masterminds/goutils v1.1.0 // indirect
 	github.com/Masterminds/semver v1.5.0 // indirect
 	github.com/Masterminds/sprig v2.22.0+incompatible
+	github.com/go-openapi/spec v0.19.5
 	github.com/huandu/xstrings v1.3.2 // indirect
 	github.com/imdario/mergo v0.3.10 // indirect
 	github.com/mitchellh/copystructure v1.0.0 // indirect
+	golang.org/x/crypto v0.0.0-20190617133340-57b3e21c3d56
 	sigs.k8s.io/kustomize/kyaml v0.4.1
 )
@@ -7,4 +7,6 @@ require (
 	sigs.k8s.io/kustomize/kyaml v0.4.1
 )
 
-//replace github.com/aodinokov/noctl-airship-poc/kpt-functions/templater => ../
+// the image is built from the source tree, so the function
+// has the config fields that aren't published yet
+replace github.com/aodinokov/noctl-airship-poc/kpt-functions/templater => ../
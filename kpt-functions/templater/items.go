@@ -0,0 +1,137 @@
+package templater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// itemsFuncs implements the template functions that
+// give access to the input items
+type itemsFuncs struct {
+	nodes []*yaml.RNode
+	// objs contains items converted to maps,
+	// so their fields can be used in templates
+	objs []map[string]interface{}
+}
+
+func newItemsFuncs(items []*yaml.RNode) (*itemsFuncs, error) {
+	f := itemsFuncs{nodes: items}
+	for _, item := range items {
+		s, err := item.String()
+		if err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		err = yaml.Unmarshal([]byte(s), &obj)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert item: %w", err)
+		}
+		f.objs = append(f.objs, obj)
+	}
+	return &f, nil
+}
+
+func (f *itemsFuncs) funcMap() template.FuncMap {
+	return template.FuncMap{
+		"getItems":  f.getItems,
+		"getByKind": f.getByKind,
+		"getByGvk":  f.getByGvk,
+		"lookup":    f.lookup,
+		"getField":  getField,
+	}
+}
+
+// selectObjs returns items with the matching apiVersion, kind,
+// namespace and name. Empty values match anything.
+func (f *itemsFuncs) selectObjs(apiVersion, kind, namespace, name string) []interface{} {
+	out := []interface{}{}
+	for i, node := range f.nodes {
+		meta, err := node.GetMeta()
+		if err != nil {
+			continue
+		}
+		if (apiVersion != "" && meta.APIVersion != apiVersion) ||
+			(kind != "" && meta.Kind != kind) ||
+			(namespace != "" && meta.Namespace != namespace) ||
+			(name != "" && meta.Name != name) {
+			continue
+		}
+		out = append(out, f.objs[i])
+	}
+	return out
+}
+
+// getItems returns all input items
+func (f *itemsFuncs) getItems() []interface{} {
+	return f.selectObjs("", "", "", "")
+}
+
+// getByKind returns input items of kind
+func (f *itemsFuncs) getByKind(kind string) []interface{} {
+	return f.selectObjs("", kind, "", "")
+}
+
+// getByGvk returns input items with apiVersion and kind
+func (f *itemsFuncs) getByGvk(apiVersion, kind string) []interface{} {
+	return f.selectObjs(apiVersion, kind, "", "")
+}
+
+// lookup returns the input item with apiVersion, kind, namespace
+// and name or empty map if there is no such item
+func (f *itemsFuncs) lookup(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+	objs := f.selectObjs(apiVersion, kind, namespace, name)
+	switch len(objs) {
+	case 0:
+		return map[string]interface{}{}, nil
+	case 1:
+		return objs[0].(map[string]interface{}), nil
+	}
+	return nil, fmt.Errorf("found more than one %s %s/%s", kind, namespace, name)
+}
+
+// getField returns the value of the field of obj by path,
+// e.g. spec.bootMACAddress or spec.networks[0].id.
+// nil is returned if there is no such field.
+func getField(obj interface{}, path string) (interface{}, error) {
+	cur := obj
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			continue
+		}
+		key := part
+		indexes := []int{}
+		if i := strings.Index(part, "["); i >= 0 {
+			key = part[:i]
+			for _, idx := range strings.Split(part[i+1:], "[") {
+				if !strings.HasSuffix(idx, "]") {
+					return nil, fmt.Errorf("wrong index in %s", path)
+				}
+				n, err := strconv.Atoi(strings.TrimSuffix(idx, "]"))
+				if err != nil {
+					return nil, fmt.Errorf("wrong index in %s: %w", path, err)
+				}
+				indexes = append(indexes, n)
+			}
+		}
+
+		if key != "" {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			cur = m[key]
+		}
+		for _, n := range indexes {
+			l, ok := cur.([]interface{})
+			if !ok || n < 0 || n >= len(l) {
+				return nil, nil
+			}
+			cur = l[n]
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,56 @@
+package templater
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetField(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "node-1",
+		},
+		"spec": map[string]interface{}{
+			"networks": []interface{}{
+				map[string]interface{}{"id": "oam"},
+				map[string]interface{}{"id": "pxe"},
+			},
+			"matrix": []interface{}{
+				[]interface{}{1, 2},
+			},
+		},
+	}
+
+	ts := []struct {
+		path        string
+		expected    interface{}
+		expectedErr bool
+	}{
+		{path: "metadata.name", expected: "node-1"},
+		{path: ".metadata.name", expected: "node-1"},
+		{path: "spec.networks[1].id", expected: "pxe"},
+		{path: "spec.matrix[0][1]", expected: 2},
+		{path: "spec.networks[2].id", expected: nil},
+		{path: "metadata.name.x", expected: nil},
+		{path: "spec.missing", expected: nil},
+		{path: "spec.networks[x]", expectedErr: true},
+		{path: "spec.networks[0", expectedErr: true},
+	}
+
+	for _, ti := range ts {
+		v, err := getField(obj, ti.path)
+		if err != nil {
+			if !ti.expectedErr {
+				t.Errorf("unexpected error for %s: %v", ti.path, err)
+			}
+			continue
+		}
+		if ti.expectedErr {
+			t.Errorf("expected error for %s", ti.path)
+			continue
+		}
+		if !reflect.DeepEqual(v, ti.expected) {
+			t.Errorf("for %s expected %v, got %v", ti.path, ti.expected, v)
+		}
+	}
+}
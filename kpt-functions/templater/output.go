@@ -0,0 +1,151 @@
+package templater
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+// Output modes that can be set in OutputMode. They define what to do
+// if the generated document has the same apiVersion, kind, namespace
+// and name as some input item
+const (
+	// AppendOutputMode appends all generated documents
+	AppendOutputMode = "append"
+	// ReplaceOutputMode replaces the input item with the generated document
+	ReplaceOutputMode = "replace"
+	// MergeOutputMode merges the generated document into the input item
+	MergeOutputMode = "merge"
+	// FailOutputMode returns error
+	FailOutputMode = "fail"
+)
+
+func isValidOutputMode(m string) bool {
+	switch m {
+	case "", AppendOutputMode, ReplaceOutputMode, MergeOutputMode, FailOutputMode:
+		return true
+	}
+	return false
+}
+
+func sameResource(a, b *yaml.RNode) (bool, error) {
+	am, err := a.GetMeta()
+	if err != nil {
+		return false, err
+	}
+	bm, err := b.GetMeta()
+	if err != nil {
+		return false, err
+	}
+	return am.APIVersion == bm.APIVersion && am.Kind == bm.Kind &&
+		am.Namespace == bm.Namespace && am.Name == bm.Name, nil
+}
+
+// findSame returns the index of the item that is the same
+// resource as node or -1 if there is no such item
+func findSame(items []*yaml.RNode, node *yaml.RNode) (int, error) {
+	for i, item := range items {
+		same, err := sameResource(item, node)
+		if err == yaml.ErrMissingMetadata {
+			continue
+		}
+		if err != nil {
+			return -1, err
+		}
+		if same {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// upsert adds the generated nodes to items according to mode
+func upsert(items, generated []*yaml.RNode, mode string) ([]*yaml.RNode, error) {
+	if mode == "" || mode == AppendOutputMode {
+		return append(items, generated...), nil
+	}
+
+	out := append([]*yaml.RNode{}, items...)
+	for _, g := range generated {
+		i, err := findSame(out, g)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			out = append(out, g)
+			continue
+		}
+
+		meta, err := g.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		switch mode {
+		case ReplaceOutputMode:
+			// keep the generated document in the same file
+			err = keepFileAnnotations(out[i], g)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = g
+		case MergeOutputMode:
+			merged, err := merge2.Merge(g, out[i])
+			if err != nil {
+				return nil, fmt.Errorf("can't merge %s %s: %w", meta.Kind, meta.Name, err)
+			}
+			// merge2 takes the annotations of the generated
+			// document, but the item must stay in the same file
+			err = keepFileAnnotations(out[i], merged)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = merged
+		case FailOutputMode:
+			return nil, fmt.Errorf("%s %s/%s already exists", meta.Kind, meta.Namespace, meta.Name)
+		default:
+			return nil, fmt.Errorf("unknown output mode %s", mode)
+		}
+	}
+	return out, nil
+}
+
+// keepFileAnnotations copies the path and index annotations from item to node
+func keepFileAnnotations(item, node *yaml.RNode) error {
+	for _, a := range []string{string(kioutil.PathAnnotation), string(kioutil.IndexAnnotation)} {
+		v, err := item.Pipe(yaml.GetAnnotation(a))
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		err = node.PipeE(yaml.SetAnnotation(a, yaml.GetValue(v)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateOnce replaces the generated nodes with the input items
+// that are the same resources. It returns the resulting nodes
+// and the generated nodes that weren't replaced.
+func generateOnce(items, generated []*yaml.RNode) ([]*yaml.RNode, []*yaml.RNode, error) {
+	nodes := []*yaml.RNode{}
+	fresh := []*yaml.RNode{}
+	for _, g := range generated {
+		i, err := findSame(items, g)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i >= 0 {
+			nodes = append(nodes, items[i])
+			continue
+		}
+		nodes = append(nodes, g)
+		fresh = append(fresh, g)
+	}
+	return nodes, fresh, nil
+}
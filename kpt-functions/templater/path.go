@@ -0,0 +1,98 @@
+package templater
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// expandPathPattern replaces {{apiVersion}}, {{group}}, {{version}},
+// {{kind}}, {{namespace}} and {{name}} in pattern with the values of node
+func expandPathPattern(pattern string, node *yaml.RNode) (string, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return "", err
+	}
+	group, version := "", meta.APIVersion
+	if i := strings.Index(meta.APIVersion, "/"); i >= 0 {
+		group, version = meta.APIVersion[:i], meta.APIVersion[i+1:]
+	}
+	// empty group of core types may leave empty directory names
+	return path.Clean(strings.NewReplacer(
+		"{{apiVersion}}", meta.APIVersion,
+		"{{group}}", group,
+		"{{version}}", version,
+		"{{kind}}", meta.Kind,
+		"{{namespace}}", meta.Namespace,
+		"{{name}}", meta.Name,
+	).Replace(pattern)), nil
+}
+
+func getAnnotation(node *yaml.RNode, key string) (string, error) {
+	v, err := node.Pipe(yaml.GetAnnotation(key))
+	if err != nil || v == nil {
+		return "", err
+	}
+	return yaml.GetValue(v), nil
+}
+
+// setOutputAnnotations sets path of the generated nodes by pattern
+// if it's set and assigns index annotations to the generated nodes with
+// path, so they follow the existing items with the same path
+// in the generated order
+func setOutputAnnotations(existing, generated []*yaml.RNode, pattern string) error {
+	if pattern != "" {
+		for _, node := range generated {
+			p, err := expandPathPattern(pattern, node)
+			if err != nil {
+				return err
+			}
+			err = node.PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, p))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// next index for each path
+	next := map[string]int{}
+	for _, item := range existing {
+		p, err := getAnnotation(item, kioutil.PathAnnotation)
+		if err != nil {
+			return err
+		}
+		if p == "" {
+			continue
+		}
+		index, err := getAnnotation(item, kioutil.IndexAnnotation)
+		if err != nil {
+			return err
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			i = 0
+		}
+		if i+1 > next[p] {
+			next[p] = i + 1
+		}
+	}
+
+	for _, node := range generated {
+		p, err := getAnnotation(node, kioutil.PathAnnotation)
+		if err != nil {
+			return err
+		}
+		if p == "" {
+			continue
+		}
+		err = node.PipeE(yaml.SetAnnotation(kioutil.IndexAnnotation, strconv.Itoa(next[p])))
+		if err != nil {
+			return err
+		}
+		next[p]++
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package templater
+
+import (
+	"bytes"
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+)
+
+func TestSetOutputAnnotations(t *testing.T) {
+	existing, err := (&kio.ByteReader{Reader: bytes.NewBufferString(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+  annotations:
+    config.kubernetes.io/path: generated/ConfigMap_x.yaml
+    config.kubernetes.io/index: '0'
+`), OmitReaderAnnotations: true}).Read()
+	if err != nil {
+		t.Fatalf("can't read items: %v", err)
+	}
+	generated, err := (&kio.ByteReader{Reader: bytes.NewBufferString(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: x
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+  namespace: metal3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: x
+`)}).Read()
+	if err != nil {
+		t.Fatalf("can't read generated: %v", err)
+	}
+
+	err = setOutputAnnotations(existing, generated, "generated/{{group}}/{{kind}}_{{name}}.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		path  string
+		index string
+	}{
+		// the existing item in the same file has index 0
+		{path: "generated/ConfigMap_x.yaml", index: "1"},
+		{path: "generated/metal3.io/BareMetalHost_node-1.yaml", index: "0"},
+		{path: "generated/ConfigMap_x.yaml", index: "2"},
+	}
+	for i, e := range expected {
+		path, _ := getAnnotation(generated[i], kioutil.PathAnnotation)
+		index, _ := getAnnotation(generated[i], kioutil.IndexAnnotation)
+		if path != e.path || index != e.index {
+			t.Errorf("node %d: expected %s:%s, got %s:%s", i, e.path, e.index, path, index)
+		}
+	}
+
+	err = setOutputAnnotations(nil, generated, "{{namespace}}/{{kind}}_{{name}}.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	index, _ := getAnnotation(generated[0], kioutil.IndexAnnotation)
+	if index != "0" {
+		t.Errorf("expected index 0 without existing items, got %s", index)
+	}
+	path, _ := getAnnotation(generated[1], kioutil.PathAnnotation)
+	if path != "metal3/BareMetalHost_node-1.yaml" {
+		t.Errorf("unexpected path %s", path)
+	}
+}
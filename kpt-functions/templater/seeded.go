@@ -0,0 +1,383 @@
+package templater
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"text/template"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	defaultSeedKey = "seed"
+	// seededNotBeforeKey is the optional key of the seed Secret with
+	// the date in RFC3339 format that is used as a start of
+	// validity period for the seeded certificates.
+	seededNotBeforeKey = "notBefore"
+	seededRSABits      = 2048
+)
+
+var (
+	defaultSeededNotBefore = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// SeedRef refers to the Secret in the input items
+// that contains the seed for the seeded template functions
+type SeedRef struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Key in data or stringData of Secret. Default is seed.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// certificate has the same fields as certificate in sprig,
+// so the seeded functions can be used instead of sprig ones
+type certificate struct {
+	Cert string
+	Key  string
+}
+
+// seeded implements the template functions that return
+// the same values for the same seed and id. id must identify
+// the resource and the field the value is generated for,
+// e.g. Secret/node1-bmc-secret/password
+type seeded struct {
+	seed      []byte
+	notBefore time.Time
+}
+
+func newSeeded(items []*yaml.RNode, ref *SeedRef) (*seeded, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultSeedKey
+	}
+
+	secret, err := findSecret(items, ref.Name, ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := secretValue(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("seed secret %s doesn't contain %s", ref.Name, key)
+	}
+
+	s := seeded{seed: seed, notBefore: defaultSeededNotBefore}
+	nb, err := secretValue(secret, seededNotBeforeKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nb) > 0 {
+		s.notBefore, err = time.Parse(time.RFC3339, string(nb))
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %s of seed secret %s: %w", seededNotBeforeKey, ref.Name, err)
+		}
+	}
+	return &s, nil
+}
+
+func findSecret(items []*yaml.RNode, name, namespace string) (*yaml.RNode, error) {
+	secret := findItem(items, "Secret", name, namespace)
+	if secret == nil {
+		return nil, fmt.Errorf("can't find secret %s in namespace '%s'", name, namespace)
+	}
+	return secret, nil
+}
+
+// secretValue returns the value from stringData or decoded value from data.
+// returns nil if there is no such key
+func secretValue(secret *yaml.RNode, key string) ([]byte, error) {
+	v, err := secret.Pipe(yaml.Lookup("stringData", key))
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		return []byte(yaml.GetValue(v)), nil
+	}
+
+	v, err = secret.Pipe(yaml.Lookup("data", key))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(yaml.GetValue(v))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// funcMap returns the seeded functions. s may be nil if the seed
+// isn't configured - in that case the functions return error
+func (s *seeded) funcMap() template.FuncMap {
+	return template.FuncMap{
+		"seededRandAlphaNum":  s.randString(alphaNum),
+		"seededRandAlpha":     s.randString(alpha),
+		"seededRandNumeric":   s.randString(numeric),
+		"seededRandAscii":     s.randString(ascii),
+		"seededGenCA":         s.genCA,
+		"seededGenSignedCert": s.genSignedCert,
+		"seededBcrypt":        s.bcryptHash,
+		"seededHtpasswd":      s.htpasswd,
+	}
+}
+
+func (s *seeded) check() error {
+	if s == nil {
+		return fmt.Errorf("seed isn't configured")
+	}
+	return nil
+}
+
+// reader returns the stream of bytes determined by seed and id
+func (s *seeded) reader(id string) io.Reader {
+	mac := hmac.New(sha256.New, s.seed)
+	mac.Write([]byte(id))
+	return &seededReader{key: mac.Sum(nil)}
+}
+
+// seededReader generates HMAC-SHA256(key, counter) blocks
+type seededReader struct {
+	key     []byte
+	counter uint64
+	buf     []byte
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			var c [8]byte
+			binary.BigEndian.PutUint64(c[:], r.counter)
+			r.counter++
+			mac := hmac.New(sha256.New, r.key)
+			mac.Write(c[:])
+			r.buf = mac.Sum(nil)
+		}
+		k := copy(p[n:], r.buf)
+		r.buf = r.buf[k:]
+		n += k
+	}
+	return n, nil
+}
+
+var (
+	alphaNum = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	alpha    = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	numeric  = []byte("0123456789")
+	ascii    = func() []byte {
+		// printable characters as in sprig randAscii
+		b := []byte{}
+		for c := byte(32); c <= 126; c++ {
+			b = append(b, c)
+		}
+		return b
+	}()
+)
+
+func (s *seeded) randString(chars []byte) func(string, int) (string, error) {
+	return func(id string, n int) (string, error) {
+		if err := s.check(); err != nil {
+			return "", err
+		}
+		r := s.reader(id)
+		// skip the values that cause the modulo bias
+		max := 256 - 256%len(chars)
+		out := make([]byte, 0, n)
+		b := make([]byte, 1)
+		for len(out) < n {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return "", err
+			}
+			if int(b[0]) >= max {
+				continue
+			}
+			out = append(out, chars[int(b[0])%len(chars)])
+		}
+		return string(out), nil
+	}
+}
+
+func seededPrime(r io.Reader, bits int) (*big.Int, error) {
+	b := make([]byte, (bits+7)/8)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		// set 2 top bits, so the product of 2 primes has 2*bits length
+		b[0] |= 0xc0
+		// prime must be odd
+		b[len(b)-1] |= 1
+		p := new(big.Int).SetBytes(b)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// seededRSAKey generates rsa key from the stream r.
+// rsa.GenerateKey can't be used since it doesn't
+// guarantee the same result for the same stream.
+func seededRSAKey(r io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+	for {
+		p, err := seededPrime(r, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := seededPrime(r, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+func (s *seeded) certTemplate(r io.Reader, cn string, daysValid int) (*x509.Certificate, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		SerialNumber: new(big.Int).SetBytes(b),
+		Subject: pkix.Name{
+			CommonName: cn,
+		},
+		NotBefore:             s.notBefore,
+		NotAfter:              s.notBefore.Add(time.Hour * 24 * time.Duration(daysValid)),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}, nil
+}
+
+func (s *seeded) certAndKey(r io.Reader, template, parent *x509.Certificate, key, parentKey *rsa.PrivateKey) (certificate, error) {
+	der, err := x509.CreateCertificate(r, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return certificate{}, fmt.Errorf("error creating certificate: %w", err)
+	}
+
+	var cert, keyOut bytes.Buffer
+	err = pem.Encode(&cert, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err != nil {
+		return certificate{}, err
+	}
+	err = pem.Encode(&keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err != nil {
+		return certificate{}, err
+	}
+	return certificate{Cert: cert.String(), Key: keyOut.String()}, nil
+}
+
+// genCA is seeded version of sprig genCA
+func (s *seeded) genCA(id, cn string, daysValid int) (certificate, error) {
+	if err := s.check(); err != nil {
+		return certificate{}, err
+	}
+	r := s.reader(id)
+	template, err := s.certTemplate(r, cn, daysValid)
+	if err != nil {
+		return certificate{}, err
+	}
+	template.KeyUsage = x509.KeyUsageKeyEncipherment |
+		x509.KeyUsageDigitalSignature |
+		x509.KeyUsageCertSign
+	template.IsCA = true
+
+	key, err := seededRSAKey(r, seededRSABits)
+	if err != nil {
+		return certificate{}, fmt.Errorf("error generating rsa key: %w", err)
+	}
+	return s.certAndKey(r, template, template, key, key)
+}
+
+// genSignedCert is seeded version of sprig genSignedCert
+func (s *seeded) genSignedCert(id, cn string, ips, alternateDNS []interface{}, daysValid int, ca certificate) (certificate, error) {
+	if err := s.check(); err != nil {
+		return certificate{}, err
+	}
+	block, _ := pem.Decode([]byte(ca.Cert))
+	if block == nil {
+		return certificate{}, fmt.Errorf("unable to decode certificate")
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return certificate{}, fmt.Errorf("error parsing certificate: %w", err)
+	}
+	block, _ = pem.Decode([]byte(ca.Key))
+	if block == nil {
+		return certificate{}, fmt.Errorf("unable to decode key")
+	}
+	caKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return certificate{}, fmt.Errorf("error parsing private key: %w", err)
+	}
+
+	r := s.reader(id)
+	template, err := s.certTemplate(r, cn, daysValid)
+	if err != nil {
+		return certificate{}, err
+	}
+	for _, ip := range ips {
+		ipStr, ok := ip.(string)
+		if !ok {
+			return certificate{}, fmt.Errorf("error parsing ip: %v is not a string", ip)
+		}
+		netIP := net.ParseIP(ipStr)
+		if netIP == nil {
+			return certificate{}, fmt.Errorf("error parsing ip: %s", ipStr)
+		}
+		template.IPAddresses = append(template.IPAddresses, netIP)
+	}
+	for _, dns := range alternateDNS {
+		dnsStr, ok := dns.(string)
+		if !ok {
+			return certificate{}, fmt.Errorf("error processing alternate dns name: %v is not a string", dns)
+		}
+		template.DNSNames = append(template.DNSNames, dnsStr)
+	}
+
+	key, err := seededRSAKey(r, seededRSABits)
+	if err != nil {
+		return certificate{}, fmt.Errorf("error generating rsa key: %w", err)
+	}
+	return s.certAndKey(r, template, caCert, key, caKey)
+}
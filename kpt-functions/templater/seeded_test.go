@@ -0,0 +1,120 @@
+package templater
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestSeeded(t *testing.T) {
+	secret, err := yaml.Parse(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: seed
+  namespace: default
+stringData:
+  seed: s3cr3t
+  notBefore: "2021-01-01T00:00:00Z"
+`)
+	if err != nil {
+		t.Fatalf("can't parse secret: %v", err)
+	}
+	s, err := newSeeded([]*yaml.RNode{secret}, &SeedRef{Name: "seed", Namespace: "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rand := s.randString(ascii)
+	a1, _ := rand("a", 32)
+	a2, _ := rand("a", 32)
+	b, _ := rand("b", 32)
+	if a1 != a2 {
+		t.Errorf("expected the same values for the same id, got %q and %q", a1, a2)
+	}
+	if a1 == b {
+		t.Errorf("expected different values for different ids, got %q", a1)
+	}
+	if len(a1) != 32 {
+		t.Errorf("expected 32 characters, got %q", a1)
+	}
+
+	ca, err := s.genCA("ca", "kubernetes", 365)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca2, err := s.genCA("ca", "kubernetes", 365)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ca != ca2 {
+		t.Errorf("expected the same ca for the same id")
+	}
+
+	cert, err := s.genSignedCert("cert", "node", []interface{}{"10.0.0.1"}, []interface{}{"node.local"}, 365, ca)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert2, err := s.genSignedCert("cert", "node", []interface{}{"10.0.0.1"}, []interface{}{"node.local"}, 365, ca)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cert != cert2 {
+		t.Errorf("expected the same cert for the same id")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(ca.Cert)) {
+		t.Fatalf("can't add ca to pool")
+	}
+	block, _ := pem.Decode([]byte(cert.Cert))
+	if block == nil {
+		t.Fatalf("can't decode cert")
+	}
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("can't parse cert: %v", err)
+	}
+	_, err = c.Verify(x509.VerifyOptions{
+		DNSName:     "node.local",
+		Roots:       roots,
+		CurrentTime: s.notBefore.AddDate(0, 1, 0),
+	})
+	if err != nil {
+		t.Errorf("cert isn't signed by ca: %v", err)
+	}
+
+	h, err := s.htpasswd("admin", "admin", "passw0rd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h2, err := s.htpasswd("admin", "admin", "passw0rd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h != h2 {
+		t.Errorf("expected the same htpasswd for the same id, got %q and %q", h, h2)
+	}
+	if !strings.HasPrefix(h, "admin:") {
+		t.Fatalf("expected htpasswd for admin, got %q", h)
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(strings.TrimPrefix(h, "admin:")), []byte("passw0rd")); err != nil {
+		t.Errorf("hash doesn't match the password: %v", err)
+	}
+	if _, err = s.htpasswd("admin", "ad:min", "passw0rd"); err == nil {
+		t.Errorf("expected error for user with ':'")
+	}
+
+	_, err = newSeeded([]*yaml.RNode{secret}, &SeedRef{Name: "seed"})
+	if err == nil {
+		t.Errorf("expected error for secret in other namespace")
+	}
+	var nilSeeded *seeded
+	if _, err = nilSeeded.randString(alpha)("a", 1); err == nil {
+		t.Errorf("expected error if seed isn't configured")
+	}
+}
@@ -0,0 +1,83 @@
+package templater
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// LibraryRef refers to ConfigMap in the input items
+// that contains the templates in data
+type LibraryRef struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Key of data with templates. All keys are used if it isn't set.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// template parses the main template, the templates from libraries
+// and the named templates. The named templates override the
+// library ones with the same name
+func (f *Function) template(items []*yaml.RNode, funcMap template.FuncMap) (*template.Template, error) {
+	tmpl := template.New("tmpl")
+
+	// include is the same as template action, but its
+	// result can be piped to other functions, e.g. indent
+	funcMap["include"] = func(name string, data interface{}) (string, error) {
+		t := tmpl.Lookup(name)
+		if t == nil {
+			return "", fmt.Errorf("template %s isn't defined", name)
+		}
+		var out bytes.Buffer
+		err := t.Execute(&out, data)
+		if err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	}
+	tmpl.Funcs(funcMap)
+
+	for _, ref := range f.Config.Libraries {
+		lib := findItem(items, "ConfigMap", ref.Name, ref.Namespace)
+		if lib == nil {
+			return nil, fmt.Errorf("can't find template library %s in namespace '%s'", ref.Name, ref.Namespace)
+		}
+		data, err := itemData(lib, false)
+		if err != nil {
+			return nil, err
+		}
+		keys := []string{}
+		for k := range data {
+			if ref.Key == "" || k == ref.Key {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("template library %s doesn't contain templates", ref.Name)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			_, err = tmpl.New(ref.Name + "/" + k).Parse(data[k])
+			if err != nil {
+				return nil, fmt.Errorf("can't parse template library %s: %w", ref.Name, err)
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range f.Config.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, err := tmpl.New(name).Parse(f.Config.Templates[name])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl.Parse(f.Config.Template)
+}
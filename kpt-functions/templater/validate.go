@@ -0,0 +1,367 @@
+package templater
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template/parse"
+	"time"
+
+	"github.com/go-openapi/spec"
+
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	intOrStringExtension           = "x-kubernetes-int-or-string"
+	preserveUnknownFieldsExtension = "x-kubernetes-preserve-unknown-fields"
+
+	typeString  = "string"
+	typeInteger = "integer"
+	typeNumber  = "number"
+	typeBoolean = "boolean"
+
+	// lineMarker encloses the template line numbers added
+	// to the rendered output by markLines
+	lineMarker = "\x00"
+)
+
+// schemaValidator validates documents against the built-in
+// kubernetes schemas and the schemas of CRDs from the input items
+type schemaValidator struct {
+	crds map[yaml.TypeMeta]*spec.Schema
+}
+
+func newSchemaValidator(items []*yaml.RNode) (*schemaValidator, error) {
+	v := schemaValidator{crds: map[yaml.TypeMeta]*spec.Schema{}}
+	for _, item := range items {
+		meta, err := item.GetMeta()
+		if err != nil || meta.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		err = v.addCRD(item)
+		if err != nil {
+			return nil, fmt.Errorf("can't read schema of CRD %s: %w", meta.Name, err)
+		}
+	}
+	return &v, nil
+}
+
+func (v *schemaValidator) addCRD(crd *yaml.RNode) error {
+	group, err := crd.Pipe(yaml.Lookup("spec", "group"))
+	if err != nil || group == nil {
+		return err
+	}
+	kind, err := crd.Pipe(yaml.Lookup("spec", "names", "kind"))
+	if err != nil || kind == nil {
+		return err
+	}
+	versions, err := crd.Pipe(yaml.Lookup("spec", "versions"))
+	if err != nil || versions == nil {
+		return err
+	}
+	// apiextensions.k8s.io/v1beta1 CRDs may have one schema for all versions
+	common, err := crd.Pipe(yaml.Lookup("spec", "validation", "openAPIV3Schema"))
+	if err != nil {
+		return err
+	}
+
+	elements, err := versions.Elements()
+	if err != nil {
+		return err
+	}
+	for _, version := range elements {
+		schemaNode, err := version.Pipe(yaml.Lookup("schema", "openAPIV3Schema"))
+		if err != nil {
+			return err
+		}
+		if schemaNode == nil {
+			schemaNode = common
+		}
+		if schemaNode == nil {
+			continue
+		}
+		s, err := toSchema(schemaNode)
+		if err != nil {
+			return err
+		}
+		t := yaml.TypeMeta{
+			APIVersion: yaml.GetValue(group) + "/" + yaml.GetValue(version.Field("name").Value),
+			Kind:       yaml.GetValue(kind),
+		}
+		v.crds[t] = s
+	}
+	return nil
+}
+
+func toSchema(node *yaml.RNode) (*spec.Schema, error) {
+	b, err := node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	s := spec.Schema{}
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (v *schemaValidator) schema(meta yaml.ResourceMeta) *spec.Schema {
+	t := yaml.TypeMeta{APIVersion: meta.APIVersion, Kind: meta.Kind}
+	if s, ok := v.crds[t]; ok {
+		return s
+	}
+	rs := openapi.SchemaForResourceType(t)
+	if rs == nil {
+		return nil
+	}
+	return rs.Schema
+}
+
+// markLines adds the line markers to the text of the template
+// tree, so the lines of the rendered output can be mapped to the
+// lines of the template with splitLines
+func markLines(node parse.Node, text string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			markLines(c, text)
+		}
+	case *parse.IfNode:
+		markLines(n.List, text)
+		markLines(n.ElseList, text)
+	case *parse.RangeNode:
+		markLines(n.List, text)
+		markLines(n.ElseList, text)
+	case *parse.WithNode:
+		markLines(n.List, text)
+		markLines(n.ElseList, text)
+	case *parse.TextNode:
+		line := 1 + strings.Count(text[:n.Position()], "\n")
+		var b strings.Builder
+		b.WriteString(lineMarker + strconv.Itoa(line) + lineMarker)
+		for _, c := range string(n.Text) {
+			b.WriteRune(c)
+			if c == '\n' {
+				line++
+				b.WriteString(lineMarker + strconv.Itoa(line) + lineMarker)
+			}
+		}
+		n.Text = []byte(b.String())
+	}
+}
+
+// splitLines removes the line markers from the rendered output and
+// returns the template line of each line of the output. The lines
+// rendered by actions get the line where the action starts
+func splitLines(out string) (string, []int) {
+	var b strings.Builder
+	lines := []int{1}
+	current, lineStart := 1, true
+	for i, part := range strings.Split(out, lineMarker) {
+		if i%2 == 1 {
+			current, _ = strconv.Atoi(part)
+			if lineStart {
+				lines[len(lines)-1] = current
+			}
+			continue
+		}
+		for _, c := range part {
+			b.WriteRune(c)
+			lineStart = c == '\n'
+			if lineStart {
+				lines = append(lines, current)
+			}
+		}
+	}
+	return b.String(), lines
+}
+
+// validateOutput validates each document of the rendered template.
+// lines maps the lines of the output to the lines of the template,
+// if it's nil the errors contain the lines of the output
+func validateOutput(items []*yaml.RNode, out string, lines []int) error {
+	v, err := newSchemaValidator(items)
+	if err != nil {
+		return err
+	}
+	templateLine := func(line int) int {
+		if line > 0 && line <= len(lines) {
+			return lines[line-1]
+		}
+		return line
+	}
+
+	// split the same way as kio.ByteReader does, but count
+	// the lines to report them relative to the whole output
+	line := 1
+	for _, doc := range strings.Split(strings.Replace(out, "\r\n", "\n", -1), "\n---\n") {
+		start := line
+		line += strings.Count(doc, "\n") + 2
+
+		node, err := yaml.Parse(doc)
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return fmt.Errorf("line %d: %w", templateLine(start), err)
+		}
+		if yaml.IsMissingOrNull(node) {
+			continue
+		}
+		err = v.validate(node, func(l int) int { return templateLine(start + l - 1) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate validates node if the schema for it is known. line
+// maps the lines of the document to the lines to report
+func (v *schemaValidator) validate(node *yaml.RNode, line func(int) int) error {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return fmt.Errorf("line %d: %w", line(1), err)
+	}
+	s := v.schema(meta)
+	if s == nil {
+		return nil
+	}
+	return validateNode(node.YNode(), s, "", line)
+}
+
+// scalarType returns the json type of the scalar value, e.g.
+// timestamps are strings after the conversion to json
+func scalarType(n *yaml.Node) string {
+	if n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	var v interface{}
+	if n.Decode(&v) != nil {
+		return ""
+	}
+	switch v.(type) {
+	case string, time.Time:
+		return typeString
+	case int, int64, uint64:
+		return typeInteger
+	case float64:
+		return typeNumber
+	case bool:
+		return typeBoolean
+	}
+	return ""
+}
+
+func resolveSchema(s *spec.Schema) (*spec.Schema, error) {
+	for s.Ref.String() != "" {
+		r, err := openapi.Resolve(&s.Ref)
+		if err != nil {
+			return nil, err
+		}
+		s = r
+	}
+	return s, nil
+}
+
+func validateNode(n *yaml.Node, s *spec.Schema, path string, line func(int) int) error {
+	s, err := resolveSchema(s)
+	if err != nil {
+		return err
+	}
+	if n.ShortTag() == yaml.NullNodeTag {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) error {
+		field := strings.TrimPrefix(path, ".")
+		if field == "" {
+			field = "document"
+		}
+		return fmt.Errorf("line %d: %s: %s", line(n.Line), field, fmt.Sprintf(format, args...))
+	}
+
+	intOrString, _ := s.Extensions.GetBool(intOrStringExtension)
+	if intOrString || s.Format == "int-or-string" {
+		if st := scalarType(n); st != typeInteger && st != typeString {
+			return fail("expected integer or string")
+		}
+		return nil
+	}
+
+	t := ""
+	if len(s.Type) == 1 {
+		t = s.Type[0]
+	}
+	switch t {
+	case typeString, typeInteger, typeBoolean:
+		if scalarType(n) != t {
+			return fail("expected %s", t)
+		}
+	case typeNumber:
+		if st := scalarType(n); st != typeInteger && st != typeNumber {
+			return fail("expected number")
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			return fail("expected array")
+		}
+		if s.Items == nil || s.Items.Schema == nil {
+			return nil
+		}
+		for i, e := range n.Content {
+			err := validateNode(e, s.Items.Schema, fmt.Sprintf("%s[%d]", path, i), line)
+			if err != nil {
+				return err
+			}
+		}
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			return fail("expected object")
+		}
+	}
+
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	preserve, _ := s.Extensions.GetBool(preserveUnknownFieldsExtension)
+	for i := 0; i < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		fs, found := s.Properties[k.Value]
+		switch {
+		case found:
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			fs = *s.AdditionalProperties.Schema
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Allows:
+			continue
+		case preserve || len(s.Properties) == 0:
+			continue
+		default:
+			return fmt.Errorf("line %d: %s: unknown field %s", line(k.Line), strings.TrimPrefix(path+"."+k.Value, "."), k.Value)
+		}
+		err := validateNode(v, &fs, path+"."+k.Value, line)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range s.Required {
+		found := false
+		for i := 0; i < len(n.Content); i += 2 {
+			if n.Content[i].Value == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("missing required field %s", r)
+		}
+	}
+	return nil
+}
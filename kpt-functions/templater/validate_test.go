@@ -0,0 +1,189 @@
+package templater
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+func TestValidateOutput(t *testing.T) {
+	crd := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: baremetalhosts.metal3.io
+spec:
+  group: metal3.io
+  names:
+    kind: BareMetalHost
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          apiVersion:
+            type: string
+          kind:
+            type: string
+          metadata:
+            type: object
+          spec:
+            type: object
+            required:
+            - online
+            properties:
+              online:
+                type: boolean
+              bootMACAddress:
+                type: string
+`
+
+	ts := []struct {
+		out         string
+		expectedErr string
+	}{
+		{
+			out: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: b
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+spec:
+  online: true
+  bootMACAddress: 00:aa:bb:cc:dd
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: x
+spec:
+  anything: 1
+`,
+		},
+		{
+			out: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+  annotations:
+    created: 2020-01-01T10:00:00Z
+data:
+  a: 2020-01-01
+`,
+		},
+		{
+			out: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+data:
+  a: 1
+`,
+			expectedErr: "line 6: data.a: expected string",
+		},
+		{
+			out: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map2
+  labelz:
+    a: b
+`,
+			expectedErr: "line 10: metadata.labelz: unknown field labelz",
+		},
+		{
+			out: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: map1
+---
+apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+spec:
+  bootMACAddress: 00:aa:bb:cc:dd
+`,
+			expectedErr: "line 11: spec: missing required field online",
+		},
+		{
+			out: `apiVersion: metal3.io/v1alpha1
+kind: BareMetalHost
+metadata:
+  name: node-1
+spec:
+  online: yes please
+`,
+			expectedErr: "line 6: spec.online: expected boolean",
+		},
+	}
+
+	items, err := (&kio.ByteReader{Reader: bytes.NewBufferString(crd)}).Read()
+	if err != nil {
+		t.Fatalf("can't read crd: %v", err)
+	}
+	for _, ti := range ts {
+		err := validateOutput(items, ti.out, nil)
+		if ti.expectedErr == "" {
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", ti.out, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), ti.expectedErr) {
+			t.Errorf("expected error %q for %s, got %v", ti.expectedErr, ti.out, err)
+		}
+	}
+}
+
+func TestValidateTemplateLines(t *testing.T) {
+	text := `{{- range .maps }}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .name }}
+data:
+  a: {{ .value }}
+{{- end }}
+`
+	tmpl, err := template.New("tmpl").Parse(text)
+	if err != nil {
+		t.Fatalf("can't parse template: %v", err)
+	}
+	markLines(tmpl.Tree.Root, text)
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, map[string]interface{}{
+		"maps": []map[string]interface{}{
+			{"name": "map1", "value": "b"},
+			{"name": "map2", "value": 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("can't execute template: %v", err)
+	}
+	rendered, lines := splitLines(out.String())
+	if strings.Contains(rendered, lineMarker) {
+		t.Errorf("rendered output contains line markers: %q", rendered)
+	}
+	err = validateOutput(nil, rendered, lines)
+	expectedErr := "line 8: data.a: expected string"
+	if err == nil || !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,183 @@
+package templater
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ValuesRef refers to ConfigMap or Secret in the input items
+type ValuesRef struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Key is the key of data that contains yaml with values.
+	// If it isn't set, all keys of data become values.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+}
+
+// ValuesFrom is the source of the values. Only one field can be set
+type ValuesFrom struct {
+	ConfigMapRef *ValuesRef `json:"configMapRef,omitempty" yaml:"configMapRef,omitempty"`
+	// Data of Secret is decoded from base64
+	SecretRef *ValuesRef `json:"secretRef,omitempty" yaml:"secretRef,omitempty"`
+	// File is the path to yaml file with values
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Optional sources are skipped if they don't exist
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// values merges values from valuesFrom in the order they are listed
+// and then the inline values, so the later sources override the former
+func (f *Function) values(items []*yaml.RNode) (map[string]interface{}, error) {
+	if len(f.Config.ValuesFrom) == 0 {
+		return f.Config.Values, nil
+	}
+
+	values := map[string]interface{}{}
+	for i, vf := range f.Config.ValuesFrom {
+		v, err := vf.values(items)
+		if err != nil {
+			return nil, fmt.Errorf("valuesFrom[%d]: %w", i, err)
+		}
+		mergeValues(values, v)
+	}
+	mergeValues(values, f.Config.Values)
+	return values, nil
+}
+
+func (vf *ValuesFrom) values(items []*yaml.RNode) (map[string]interface{}, error) {
+	set := 0
+	if vf.ConfigMapRef != nil {
+		set++
+	}
+	if vf.SecretRef != nil {
+		set++
+	}
+	if vf.File != "" {
+		set++
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one of configMapRef, secretRef or file must be set")
+	}
+
+	if vf.File != "" {
+		b, err := ioutil.ReadFile(vf.File)
+		if err != nil {
+			if vf.Optional {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return parseValues(string(b))
+	}
+
+	kind, ref := "ConfigMap", vf.ConfigMapRef
+	if vf.SecretRef != nil {
+		kind, ref = "Secret", vf.SecretRef
+	}
+
+	obj := findItem(items, kind, ref.Name, ref.Namespace)
+	if obj == nil {
+		if vf.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't find %s %s in namespace '%s'", kind, ref.Name, ref.Namespace)
+	}
+
+	data, err := itemData(obj, kind == "Secret")
+	if err != nil {
+		return nil, err
+	}
+	if ref.Key == "" {
+		values := map[string]interface{}{}
+		for k, v := range data {
+			values[k] = v
+		}
+		return values, nil
+	}
+	v, ok := data[ref.Key]
+	if !ok {
+		if vf.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s %s doesn't contain %s", kind, ref.Name, ref.Key)
+	}
+	return parseValues(v)
+}
+
+func findItem(items []*yaml.RNode, kind, name, namespace string) *yaml.RNode {
+	for _, item := range items {
+		meta, err := item.GetMeta()
+		if err != nil {
+			continue
+		}
+		if meta.Kind == kind && meta.Name == name && meta.Namespace == namespace {
+			return item
+		}
+	}
+	return nil
+}
+
+// itemData returns data of ConfigMap or Secret. If decode is set
+// data is decoded from base64 and stringData is added
+func itemData(obj *yaml.RNode, decode bool) (map[string]string, error) {
+	out := map[string]string{}
+	fields := []string{"data"}
+	if decode {
+		fields = append(fields, "stringData")
+	}
+	for _, field := range fields {
+		node, err := obj.Pipe(yaml.Lookup(field))
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			continue
+		}
+		err = node.VisitFields(func(n *yaml.MapNode) error {
+			k, v := yaml.GetValue(n.Key), yaml.GetValue(n.Value)
+			if decode && field == "data" {
+				b, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					return fmt.Errorf("can't decode %s: %w", k, err)
+				}
+				v = string(b)
+			}
+			out[k] = v
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func parseValues(s string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	err := yaml.Unmarshal([]byte(s), &values)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse values: %w", err)
+	}
+	return values, nil
+}
+
+// mergeValues merges src into dst recursively.
+// Values that aren't maps are replaced.
+func mergeValues(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm = map[string]interface{}{}
+			dst[k] = dm
+		}
+		mergeValues(dm, sm)
+	}
+}
@@ -0,0 +1,55 @@
+package templater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValuesFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templater")
+	if err != nil {
+		t.Fatalf("can't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "values.yaml")
+	err = ioutil.WriteFile(path, []byte("a:\n  b: 1\n  c: 2\nd: [1]\n"), 0644)
+	if err != nil {
+		t.Fatalf("can't write values: %v", err)
+	}
+
+	f := Function{Config: &FunctionConfig{
+		ValuesFrom: []ValuesFrom{
+			{File: path},
+			{File: filepath.Join(dir, "missing.yaml"), Optional: true},
+		},
+		Values: map[string]interface{}{
+			"a": map[string]interface{}{"c": 3},
+			"d": []interface{}{2},
+		},
+	}}
+	values, err := f.values(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": 3},
+		"d": []interface{}{2},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	f.Config.ValuesFrom = []ValuesFrom{{File: filepath.Join(dir, "missing.yaml")}}
+	if _, err = f.values(nil); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+
+	f.Config.ValuesFrom = []ValuesFrom{{File: path, ConfigMapRef: &ValuesRef{Name: "x"}}}
+	if _, err = f.values(nil); err == nil {
+		t.Errorf("expected error if several sources are set")
+	}
+}